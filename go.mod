@@ -1,8 +1,10 @@
 module github.com/BTMichalowicz/go_software_build
 
-go 1.13
+go 1.20
 
 require (
 	github.com/BTMichalowicz/go_exec main
 	github.com/BTMichalowicz/go_util main
+	github.com/BurntSushi/toml v1.3.2
+	gopkg.in/yaml.v3 v3.0.1
 )
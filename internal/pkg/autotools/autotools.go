@@ -7,6 +7,7 @@
 package autotools
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -15,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
@@ -46,18 +48,29 @@ type Config struct {
 
 	// ConfigurePreludeCmd is the command to invoke before trying to configure the software
 	ConfigurePreludeCmd string
+
+	// ManifestDir is the directory where the manifests of the configuration commands are stored.
+	// When empty, the manifests are stored in the install directory.
+	ManifestDir string
+}
+
+func (cfg *Config) getManifestDir() string {
+	if cfg.ManifestDir != "" {
+		return cfg.ManifestDir
+	}
+	return cfg.Install
 }
 
-func autogen(cfg *Config) error {
+func autogen(ctx context.Context, cfg *Config) error {
 	if !cfg.HasAutogen {
-		log.Println("-> no autogen.sh script, skipping")
+		process.Logf(ctx, "-> no autogen.sh script, skipping")
 		return nil
 	}
 
 	// From here we know that an autogen script is present
 	configureScriptPath := filepath.Join(cfg.Source, "configure")
 	if util.FileExists(configureScriptPath) {
-		log.Println("-> configure script already exists, skipping")
+		process.Logf(ctx, "-> configure script already exists, skipping")
 		return nil
 	}
 
@@ -69,10 +82,10 @@ func autogen(cfg *Config) error {
 		cmd.BinPath = "./autogen.pl"
 	}
 	cmd.ManifestName = "autogen"
-	cmd.ManifestDir = cfg.Install
+	cmd.ManifestDir = cfg.getManifestDir()
 	cmd.ExecDir = cfg.Source
 	cmd.Env = cfg.ConfigureEnv
-	res := cmd.Run()
+	res := process.Run(ctx, &cmd)
 	if res.Err != nil {
 		return fmt.Errorf("unable to run autogen from %s, command failed: %w - stdout: %s - stderr: %s", cfg.Source, res.Err, res.Stdout, res.Stderr)
 	}
@@ -147,6 +160,11 @@ func (cfg *Config) Detect() {
 
 // Configure handles the classic configure commands
 func (cfg *Config) Configure() error {
+	return cfg.ConfigureContext(context.Background())
+}
+
+// ConfigureContext handles the classic configure commands, stopping when the context is done
+func (cfg *Config) ConfigureContext(ctx context.Context) error {
 	cfg.Detect()
 
 	// Run any configure prelude first
@@ -161,22 +179,23 @@ func (cfg *Config) Configure() error {
 		preludeCmd.BinPath = cmdBin
 		preludeCmd.CmdArgs = append(preludeCmd.CmdArgs, tokens[1:]...)
 		preludeCmd.ManifestName = "configure_prelude"
-		preludeCmd.ManifestDir = cfg.Install
+		preludeCmd.ManifestDir = cfg.getManifestDir()
 		preludeCmd.ExecDir = cfg.Source
-		res := preludeCmd.Run()
+		preludeCmd.Env = cfg.ConfigureEnv
+		res := process.Run(ctx, &preludeCmd)
 		if res.Err != nil {
 			return fmt.Errorf("unable to execute configure prelude %s: %w", cfg.ConfigurePreludeCmd, res.Err)
 		}
 	}
 
 	// Run autogen when necessary
-	err := autogen(cfg)
+	err := autogen(ctx, cfg)
 	if err != nil {
 		return err
 	}
 
 	if !cfg.HasConfigure {
-		log.Printf("-> Package does not have configure script, skipping the configuration step\n")
+		process.Logf(ctx, "-> Package does not have configure script, skipping the configuration step\n")
 		return nil
 	}
 
@@ -190,18 +209,18 @@ func (cfg *Config) Configure() error {
 	}
 
 	configurePath := filepath.Join(cfg.Source, "configure")
-	log.Printf("-> Running 'configure': %s %s\n", configurePath, cmdArgs)
+	process.Logf(ctx, "-> Running 'configure': %s %s\n", configurePath, cmdArgs)
 	var cmd advexec.Advcmd
 	cmd.BinPath = "./configure"
 	cmd.ManifestName = "configure"
-	cmd.ManifestDir = cfg.Install
+	cmd.ManifestDir = cfg.getManifestDir()
 	if len(cmdArgs) > 0 {
 		cmd.ManifestData = []string{strings.Join(cmdArgs, " ")}
 		cmd.CmdArgs = cmdArgs
 	}
 	cmd.ExecDir = cfg.Source
 	cmd.Env = append(cmd.Env, cfg.ConfigureEnv...)
-	res := cmd.Run()
+	res := process.Run(ctx, &cmd)
 	if res.Err != nil {
 		return fmt.Errorf("command failed: %s - stdout: %s - stderr: %s", res.Err, res.Stdout, res.Stderr)
 	}
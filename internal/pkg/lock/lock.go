@@ -0,0 +1,215 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package lock provides advisory file locks to coordinate processes sharing the same directories.
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+)
+
+// pollInterval is how often a lock held by another process is checked while waiting for it
+const pollInterval = 200 * time.Millisecond
+
+// errWouldBlock is returned by tryLockFile when the lock is held by another process
+var errWouldBlock = errors.New("lock held by another process")
+
+// Options specifies how a lock is acquired
+type Options struct {
+	// Shared specifies whether the lock can be held by several processes at the same time. An exclusive
+	// lock cannot be acquired while shared locks are held, and the other way around.
+	Shared bool
+
+	// Wait specifies whether to wait until the lock is released by other processes instead of failing
+	// immediately
+	Wait bool
+}
+
+// Holder describes the process holding an exclusive lock
+type Holder struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	User    string    `json:"user"`
+	Started time.Time `json:"started"`
+}
+
+// Stale returns whether the holder is a process of the current host that is no longer running, meaning
+// it exited without releasing the lock
+func (h *Holder) Stale() bool {
+	host, err := os.Hostname()
+	if err != nil || host != h.Host {
+		// Processes of other hosts cannot be checked
+		return false
+	}
+	return !processExists(h.PID)
+}
+
+func (h *Holder) String() string {
+	return fmt.Sprintf("pid %d on %s (user %s) since %s", h.PID, h.Host, h.User, h.Started.Format(time.RFC3339))
+}
+
+func currentHolder() *Holder {
+	h := &Holder{PID: os.Getpid(), Started: time.Now()}
+	h.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		h.User = u.Username
+	}
+	return h
+}
+
+// LockedError is the error returned when a lock is held by another process and the caller did not
+// request to wait for it
+type LockedError struct {
+	// Path is the path to the lock file
+	Path string
+
+	// Holder is the process holding the lock, nil if unknown, for instance for shared locks
+	Holder *Holder
+}
+
+func (e *LockedError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("%s is locked by another process", e.Path)
+	}
+	msg := fmt.Sprintf("%s is locked by %s", e.Path, e.Holder)
+	// Only exclusive holders are recorded so a holder that is no longer running left its metadata
+	// behind when interrupted, and the lock is now shared by other processes
+	if e.Holder.Stale() {
+		msg += ", which is no longer running: the lock is currently shared by other processes"
+	}
+	return msg
+}
+
+// ReadHolder returns the process holding the exclusive lock on a file, nil if the file does not have
+// any holder metadata
+func ReadHolder(path string) (*Holder, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if len(content) == 0 {
+		return nil, nil
+	}
+	h := new(Holder)
+	err = json.Unmarshal(content, h)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return h, nil
+}
+
+// FileLock is an advisory lock held on a file
+type FileLock struct {
+	f      *os.File
+	shared bool
+}
+
+// Acquire creates the lock file if it does not exist and waits until the exclusive lock on it is acquired
+func Acquire(path string) (*FileLock, error) {
+	return AcquireContext(context.Background(), path, Options{Wait: true})
+}
+
+// AcquireContext creates the lock file if it does not exist and acquires the lock on it. When the
+// lock is held by another process, a *LockedError is returned unless the options request to wait, in
+// which case the lock is acquired as soon as it is released or an error is returned when the context
+// is done. The process holding an exclusive lock is recorded in the lock file.
+func AcquireContext(ctx context.Context, path string, opts Options) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+
+	waiting := false
+	for {
+		err = tryLockFile(f, opts.Shared)
+		if err == nil {
+			break
+		}
+		if err != errWouldBlock {
+			f.Close()
+			return nil, fmt.Errorf("unable to lock %s: %w", path, err)
+		}
+
+		holder, _ := ReadHolder(path)
+		lockedErr := &LockedError{Path: path, Holder: holder}
+		if !opts.Wait {
+			f.Close()
+			return nil, lockedErr
+		}
+		if !waiting {
+			process.Logf(ctx, "* %s, waiting...", lockedErr)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, fmt.Errorf("interrupted while waiting for the lock: %w", lockedErr)
+		case <-time.After(pollInterval):
+		}
+	}
+
+	l := &FileLock{f: f, shared: opts.Shared}
+	if opts.Shared {
+		return l, nil
+	}
+
+	// An exclusive lock has no holder metadata once released so metadata left in the file means the
+	// previous holder did not complete
+	previous, _ := ReadHolder(path)
+	if previous != nil {
+		process.Logf(ctx, "* The previous holder of %s, %s, did not release the lock, it may have been interrupted", path, previous)
+	}
+	err = l.writeHolder(currentHolder())
+	if err != nil {
+		l.Release()
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileLock) writeHolder(h *Holder) error {
+	var content []byte
+	if h != nil {
+		var err error
+		content, err = json.Marshal(h)
+		if err != nil {
+			return fmt.Errorf("unable to marshal the lock holder: %w", err)
+		}
+	}
+	err := l.f.Truncate(0)
+	if err == nil {
+		_, err = l.f.WriteAt(content, 0)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write the lock holder in %s: %w", l.f.Name(), err)
+	}
+	return nil
+}
+
+// Release releases the lock. The lock file is left in place so other processes waiting for the lock
+// keep using the same file.
+func (l *FileLock) Release() error {
+	if !l.shared {
+		_ = l.writeHolder(nil)
+	}
+	err := unlockFile(l.f)
+	closeErr := l.f.Close()
+	if err != nil {
+		return fmt.Errorf("unable to unlock %s: %w", l.f.Name(), err)
+	}
+	return closeErr
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExclusiveLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.lock")
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("unable to acquire the lock: %s", err)
+	}
+	holder, err := ReadHolder(path)
+	if err != nil {
+		t.Fatalf("unable to read the holder: %s", err)
+	}
+	if holder == nil || holder.PID != os.Getpid() {
+		t.Fatalf("holder is %v instead of the current process", holder)
+	}
+	if holder.Stale() {
+		t.Fatalf("the current process is reported as stale")
+	}
+
+	for _, opts := range []Options{{}, {Shared: true}} {
+		_, err = AcquireContext(context.Background(), path, opts)
+		var lockedErr *LockedError
+		if !errors.As(err, &lockedErr) {
+			t.Fatalf("acquiring a locked file with %+v returned %v instead of a LockedError", opts, err)
+		}
+		if lockedErr.Holder == nil || lockedErr.Holder.PID != os.Getpid() {
+			t.Fatalf("the error does not report the holder: %s", lockedErr)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*pollInterval)
+	defer cancel()
+	_, err = AcquireContext(ctx, path, Options{Wait: true})
+	if err == nil {
+		t.Fatalf("waiting for a lock that is never released succeeded")
+	}
+
+	err = l.Release()
+	if err != nil {
+		t.Fatalf("unable to release the lock: %s", err)
+	}
+	holder, err = ReadHolder(path)
+	if err != nil {
+		t.Fatalf("unable to read the holder: %s", err)
+	}
+	if holder != nil {
+		t.Fatalf("the released lock still has a holder: %s", holder)
+	}
+
+	l, err = AcquireContext(context.Background(), path, Options{})
+	if err != nil {
+		t.Fatalf("unable to acquire the released lock: %s", err)
+	}
+	l.Release()
+}
+
+func TestSharedLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.lock")
+
+	first, err := AcquireContext(context.Background(), path, Options{Shared: true})
+	if err != nil {
+		t.Fatalf("unable to acquire the shared lock: %s", err)
+	}
+	second, err := AcquireContext(context.Background(), path, Options{Shared: true})
+	if err != nil {
+		t.Fatalf("unable to acquire the shared lock twice: %s", err)
+	}
+
+	_, err = AcquireContext(context.Background(), path, Options{})
+	var lockedErr *LockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("acquiring an exclusive lock while shared locks are held returned %v", err)
+	}
+	if lockedErr.Holder != nil {
+		t.Fatalf("shared locks reported a holder: %s", lockedErr)
+	}
+
+	first.Release()
+	acquired := make(chan error)
+	go func() {
+		l, err := AcquireContext(context.Background(), path, Options{Wait: true})
+		if err == nil {
+			l.Release()
+		}
+		acquired <- err
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("the exclusive lock was acquired while a shared lock is held (err: %v)", err)
+	case <-time.After(2 * pollInterval):
+	}
+	second.Release()
+	err = <-acquired
+	if err != nil {
+		t.Fatalf("unable to acquire the exclusive lock once released: %s", err)
+	}
+}
+
+func TestStaleHolder(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Skipf("unable to get the host name: %s", err)
+	}
+	// The PID of the current process plus a large offset is assumed not to be running
+	h := &Holder{PID: os.Getpid() + 1<<22, Host: host}
+	if !h.Stale() {
+		t.Fatalf("holder %s is not reported as stale", h)
+	}
+	h.Host = host + ".other"
+	if h.Stale() {
+		t.Fatalf("holder %s of another host is reported as stale", h)
+	}
+
+	// An interrupted exclusive holder leaves its metadata behind while other processes share the lock
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.lock")
+	h.Host = host
+	content, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("unable to marshal the holder: %s", err)
+	}
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", path, err)
+	}
+	shared, err := AcquireContext(context.Background(), path, Options{Shared: true})
+	if err != nil {
+		t.Fatalf("unable to acquire the shared lock: %s", err)
+	}
+	defer shared.Release()
+	_, err = AcquireContext(context.Background(), path, Options{})
+	if err == nil || !strings.Contains(err.Error(), "no longer running: the lock is currently shared by other processes") {
+		t.Fatalf("the stale holder is not reported: %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+//go:build windows
+// +build windows
+
+package lock
+
+import (
+	"log"
+	"os"
+	"sync"
+)
+
+var unsupportedWarning sync.Once
+
+func tryLockFile(f *os.File, shared bool) error {
+	// Advisory locks are not supported, processes are not coordinated
+	unsupportedWarning.Do(func() {
+		log.Printf("* File locks are not supported on this platform, concurrent processes using the same directories are not coordinated")
+	})
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+func processExists(pid int) bool {
+	// Processes cannot be checked so a holder is never considered stale
+	return true
+}
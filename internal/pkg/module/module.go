@@ -8,6 +8,7 @@ package module
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 )
@@ -70,7 +71,12 @@ func Generate(path, copyright, customEnvVarPrefix, name string, requires []strin
 		}
 	}
 
-	err := ioutil.WriteFile(modulefilePath, []byte(content), defaultPermission)
+	// The name of a modulefile can include a version, e.g., <name>/<version>
+	err := os.MkdirAll(filepath.Dir(modulefilePath), defaultPermission)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(modulefilePath), err)
+	}
+	err = ioutil.WriteFile(modulefilePath, []byte(content), defaultPermission)
 	if err != nil {
 		return fmt.Errorf("unable to write content of %s: %w", modulefilePath, err)
 	}
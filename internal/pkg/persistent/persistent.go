@@ -3,4 +3,232 @@
 // LICENSE.md file distributed with the sources of this project regarding your
 // rights to use or distribute this software.
 
+// Package persistent provides the registry of the software packages installed in a persistent
+// install directory, which is shared by all the builds of a user using the same directory. The
+// registry is kept in the cache directory of the user, and not in the persistent directory, so it can
+// be updated even when installing in the persistent directory requires privileges.
 package persistent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/lock"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// registryDirName is the name of the directory, in the cache directory of the user, with the
+	// registries of all the persistent directories
+	registryDirName = "go_software_build/persistent"
+
+	// registryFileName is the name of the file, in the directory of a registry, where the registry is saved
+	registryFileName = "registry.json"
+
+	// lockFileName is the name of the file, in the directory of a registry, locked while the registry
+	// is accessed
+	lockFileName = "registry.lock"
+)
+
+// Entry is a software package installed in the persistent directory
+type Entry struct {
+	// Name is the name of the software package
+	Name string `json:"name"`
+
+	// Version is the version of the software package, empty when not specified
+	Version string `json:"version,omitempty"`
+
+	// URL is where the source code of the software package comes from
+	URL string `json:"URL"`
+
+	// Branch is the branch of the source code when it comes from a Git repository
+	Branch string `json:"branch,omitempty"`
+
+	// Commit is the commit the source code was pinned to, if any
+	Commit string `json:"commit,omitempty"`
+
+	// Digest is the digest the source code was pinned to, if any
+	Digest string `json:"digest,omitempty"`
+
+	// InstallDir is the directory where the software package is installed
+	InstallDir string `json:"install_dir"`
+
+	// BuildTime is when the installation completed
+	BuildTime time.Time `json:"build_time"`
+
+	// InputHash is the hash of all the inputs of the installation
+	InputHash string `json:"input_hash"`
+}
+
+// Registry is the list of the software packages installed in a persistent directory. Every operation
+// locks the registry so it can safely be used by concurrent processes.
+type Registry struct {
+	root string
+}
+
+type database struct {
+	// Root is the persistent directory of the registry
+	Root    string  `json:"root"`
+	Entries []Entry `json:"entries"`
+}
+
+// NewRegistry returns the registry of the persistent directory root
+func NewRegistry(root string) *Registry {
+	return &Registry{root: root}
+}
+
+// dir returns the directory of the registry, which is specific to the persistent directory
+func (r *Registry) dir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to get the cache directory: %w", err)
+	}
+	root, err := filepath.Abs(r.root)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the absolute path of %s: %w", r.root, err)
+	}
+	h := sha256.Sum256([]byte(root))
+	return filepath.Join(cacheDir, registryDirName, hex.EncodeToString(h[:])), nil
+}
+
+func (r *Registry) load(path string) (*database, error) {
+	db := &database{Root: r.root}
+	if !util.FileExists(path) {
+		return db, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	err = json.Unmarshal(content, db)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return db, nil
+}
+
+func (r *Registry) save(path string, db *database) error {
+	content, err := json.MarshalIndent(db, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the registry: %w", err)
+	}
+	// The registry is written to a temporary file first so an interruption never leaves a corrupted registry
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("unable to move %s to %s: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// read returns the content of the registry. Nothing is created when the registry does not exist.
+func (r *Registry) read() (*database, error) {
+	dir, err := r.dir()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, registryFileName)
+	if !util.FileExists(path) {
+		return &database{Root: r.root}, nil
+	}
+	l, err := lock.Acquire(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return nil, err
+	}
+	defer l.Release()
+	return r.load(path)
+}
+
+// update executes a function on the content of the registry while holding the lock, saving the
+// registry when the function reports a change
+func (r *Registry) update(fn func(db *database) bool) error {
+	dir, err := r.dir()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", dir, err)
+	}
+	l, err := lock.Acquire(filepath.Join(dir, lockFileName))
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	path := filepath.Join(dir, registryFileName)
+	db, err := r.load(path)
+	if err != nil {
+		return err
+	}
+	if fn(db) {
+		return r.save(path, db)
+	}
+	return nil
+}
+
+func (db *database) find(name string, version string) int {
+	for idx, entry := range db.Entries {
+		if entry.Name == name && entry.Version == version {
+			return idx
+		}
+	}
+	return -1
+}
+
+// Get returns the entry of a version of a software package, nil if it is not registered
+func (r *Registry) Get(name string, version string) (*Entry, error) {
+	db, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	idx := db.find(name, version)
+	if idx == -1 {
+		return nil, nil
+	}
+	return &db.Entries[idx], nil
+}
+
+// List returns all the entries of the registry
+func (r *Registry) List() ([]Entry, error) {
+	db, err := r.read()
+	if err != nil {
+		return nil, err
+	}
+	return db.Entries, nil
+}
+
+// Register adds a software package to the registry, replacing the entry of the same version if any
+func (r *Registry) Register(entry Entry) error {
+	return r.update(func(db *database) bool {
+		idx := db.find(entry.Name, entry.Version)
+		if idx == -1 {
+			db.Entries = append(db.Entries, entry)
+		} else {
+			db.Entries[idx] = entry
+		}
+		return true
+	})
+}
+
+// Unregister removes a version of a software package from the registry
+func (r *Registry) Unregister(name string, version string) error {
+	return r.update(func(db *database) bool {
+		idx := db.find(name, version)
+		if idx == -1 {
+			return false
+		}
+		db.Entries = append(db.Entries[:idx], db.Entries[idx+1:]...)
+		return true
+	})
+}
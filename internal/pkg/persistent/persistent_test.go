@@ -0,0 +1,112 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package persistent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	root, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(root)
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+
+	r := NewRegistry(root)
+	entry, err := r.Get("hwloc", "2.0")
+	if err != nil {
+		t.Fatalf("unable to get an entry from an empty registry: %s", err)
+	}
+	if entry != nil {
+		t.Fatalf("an empty registry returned %+v", entry)
+	}
+	// Reading a registry that does not exist does not create anything
+	if content, _ := ioutil.ReadDir(cacheDir); len(content) != 0 {
+		t.Fatalf("reading an empty registry created %s", content[0].Name())
+	}
+
+	entries := []Entry{
+		{Name: "hwloc", Version: "2.0", URL: "https://example.com/hwloc-2.0.tar.bz2", InputHash: "a"},
+		{Name: "hwloc", URL: "https://example.com/hwloc.git", Branch: "master", InputHash: "b"},
+		{Name: "ompi", Version: "4.0", URL: "https://example.com/ompi-4.0.tar.bz2", InputHash: "c"},
+	}
+	for _, e := range entries {
+		err := r.Register(e)
+		if err != nil {
+			t.Fatalf("unable to register %s: %s", e.Name, err)
+		}
+	}
+
+	// Nothing is written in the persistent directory, which may require privileges
+	if content, _ := ioutil.ReadDir(root); len(content) != 0 {
+		t.Fatalf("the registry created %s in the persistent directory", content[0].Name())
+	}
+	other, err := NewRegistry(filepath.Join(root, "other")).List()
+	if err != nil || len(other) != 0 {
+		t.Fatalf("the registry of another directory is not empty (%v): %+v", err, other)
+	}
+
+	// A new registry on the same directory sees the same entries
+	r = NewRegistry(root)
+	list, err := r.List()
+	if err != nil {
+		t.Fatalf("unable to list the registry: %s", err)
+	}
+	if len(list) != len(entries) {
+		t.Fatalf("registry has %d entries instead of %d", len(list), len(entries))
+	}
+	entry, err = r.Get("hwloc", "")
+	if err != nil {
+		t.Fatalf("unable to get hwloc: %s", err)
+	}
+	if entry == nil || entry.InputHash != "b" {
+		t.Fatalf("the unversioned hwloc is %+v", entry)
+	}
+
+	// Registering the same version replaces the entry
+	updated := entries[0]
+	updated.InputHash = "d"
+	err = r.Register(updated)
+	if err != nil {
+		t.Fatalf("unable to register hwloc again: %s", err)
+	}
+	entry, err = r.Get("hwloc", "2.0")
+	if err != nil {
+		t.Fatalf("unable to get hwloc 2.0: %s", err)
+	}
+	if entry == nil || entry.InputHash != "d" {
+		t.Fatalf("hwloc 2.0 was not replaced: %+v", entry)
+	}
+
+	err = r.Unregister("hwloc", "2.0")
+	if err != nil {
+		t.Fatalf("unable to unregister hwloc 2.0: %s", err)
+	}
+	err = r.Unregister("hwloc", "3.0")
+	if err != nil {
+		t.Fatalf("unregistering an unknown version failed: %s", err)
+	}
+	list, err = r.List()
+	if err != nil {
+		t.Fatalf("unable to list the registry: %s", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("registry has %d entries instead of 2 after removing one", len(list))
+	}
+	entry, err = r.Get("hwloc", "2.0")
+	if err != nil {
+		t.Fatalf("unable to get hwloc 2.0: %s", err)
+	}
+	if entry != nil {
+		t.Fatalf("hwloc 2.0 is still registered: %+v", entry)
+	}
+}
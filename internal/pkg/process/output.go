@@ -0,0 +1,47 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package process
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+)
+
+type outputKey struct{}
+
+// WithOutput returns a copy of a context where the output of all the commands executed with the context,
+// as well as all the messages logged with Logf, are written to w. It is used to give each concurrent
+// build its own log.
+func WithOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputKey{}, w)
+}
+
+func getOutput(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputKey{}).(io.Writer)
+	return w
+}
+
+// Tee returns a writer that writes both to w and to the output associated to the context, if any
+func Tee(ctx context.Context, w io.Writer) io.Writer {
+	output := getOutput(ctx)
+	if output == nil {
+		return w
+	}
+	return io.MultiWriter(w, output)
+}
+
+// Logf logs a message to the output associated to the context or, when the context does not have
+// any, to the standard logger
+func Logf(ctx context.Context, format string, v ...interface{}) {
+	output := getOutput(ctx)
+	if output == nil {
+		log.Output(2, fmt.Sprintf(format, v...))
+		return
+	}
+	log.New(output, "", log.LstdFlags).Output(2, fmt.Sprintf(format, v...))
+}
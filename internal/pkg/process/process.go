@@ -0,0 +1,60 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package process provides the capabilities to run commands that can be cancelled through a context,
+// making sure that no child process is left behind when it happens.
+package process
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+)
+
+const (
+	// waitDelay is how long we wait for the I/O of a killed command to complete
+	waitDelay = 10 * time.Second
+)
+
+// Command returns the command to execute a binary with a set of arguments. When the context can be
+// cancelled, the command is executed in its own process group and the entire group is killed when the
+// context is done.
+func Command(ctx context.Context, bin string, args ...string) *exec.Cmd {
+	if ctx.Done() == nil {
+		// The context can never be cancelled, the command stays in our process group so it keeps
+		// receiving the signals from the terminal, e.g., Ctrl-C
+		return exec.Command(bin, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd)
+	}
+	cmd.WaitDelay = waitDelay
+	return cmd
+}
+
+// Run executes an advexec command, killing it and all its children when the context is done. The
+// command is always created here so advexec never applies its own default timeout: the context is the
+// only limit to the execution of the command.
+func Run(ctx context.Context, cmd *advexec.Advcmd) advexec.Result {
+	var stdout, stderr bytes.Buffer
+	cmd.Cmd = Command(ctx, cmd.BinPath, cmd.CmdArgs...)
+	cmd.Cmd.Stdout = Tee(ctx, &stdout)
+	cmd.Cmd.Stderr = Tee(ctx, &stderr)
+	cmd.Cmd.Env = append(cmd.Cmd.Env, cmd.Env...)
+	res := cmd.Run()
+	res.Stdout = stdout.String()
+	res.Stderr = stderr.String()
+	if res.Err != nil && ctx.Err() != nil {
+		res.Err = fmt.Errorf("%w: %s", ctx.Err(), res.Err)
+	}
+	return res
+}
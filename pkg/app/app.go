@@ -16,6 +16,12 @@ type SourceCode struct {
 
 	// Command to execute before checking out a branch
 	BranchCheckoutPrelude string
+
+	// Commit is the exact commit to check out from a Git repository, taking precedence over Branch
+	Commit string
+
+	// Digest is the digest, e.g., "sha256:<hex>", the source code must match when it is a file
+	Digest string
 }
 
 // Info gathers information about a given application
@@ -38,7 +44,8 @@ type Info struct {
 	// InstallCmd is the command to execute to install the app (in case it is not a standard command)
 	InstallCmd string
 
-	// Version is the version of the application to concider
+	// Version is the version of the application to concider. When set, the application is installed in
+	// <name>/<version> so several versions can be installed side by side.
 	Version string
 
 	// Tarball is the name of the tarball of the application
@@ -11,7 +11,11 @@ package buildenv
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -21,12 +25,44 @@ import (
 	"strings"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
 	"github.com/BTMichalowicz/go_software_build/pkg/app"
+	"github.com/BTMichalowicz/go_software_build/pkg/privilege"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
 const (
 	defaultDirMode = 0755
+
+	// stagingDirName is the name of the directory, within the install directory, where software
+	// packages are installed before being moved to their final location
+	stagingDirName = ".staging"
+
+	// manifestDirSuffix is the suffix of the directory, next to the application's build directory,
+	// where manifests are stored until the application is installed
+	manifestDirSuffix = ".manifests"
+
+	// DefaultHermeticPath is the value of PATH in a hermetic environment when Info.HermeticPath is empty,
+	// unless PATH is explicitly passed through
+	DefaultHermeticPath = "/usr/local/bin:/usr/bin:/bin"
+
+	// hermeticLang is the value of LANG in a hermetic environment, unless LANG is explicitly passed through
+	hermeticLang = "C"
+)
+
+// EnvMode specifies how the environment of the commands executed in a build environment is created
+type EnvMode string
+
+const (
+	// EnvInherit means that the environment of the commands is the environment of the current process
+	// extended with Info.Env
+	EnvInherit EnvMode = ""
+
+	// EnvHermetic means that the environment of the commands is a minimal environment (PATH, HOME,
+	// TMPDIR and LANG) extended with the passthrough variables and Info.Env. PATH is Info.HermeticPath,
+	// DefaultHermeticPath by default, so tools installed elsewhere, e.g., in /opt or with a module, must
+	// be added to it or PATH must be passed through.
+	EnvHermetic EnvMode = "hermetic"
 )
 
 // Info gathers the details of the build environment
@@ -54,6 +90,16 @@ type Info struct {
 	// Env is the environment to use with the build environment
 	Env []string
 
+	// EnvMode specifies how the environment of the commands is created, see GetEffectiveEnv()
+	EnvMode EnvMode
+
+	// EnvPassthrough is the list of the environment variables of the current process that are passed
+	// to the commands when using a hermetic environment
+	EnvPassthrough []string
+
+	// HermeticPath is the value of PATH in a hermetic environment, DefaultHermeticPath when empty
+	HermeticPath string
+
 	// ConfigureExtraArgs is the extra arguments to use when running configure
 	ConfigureExtraArgs []string
 
@@ -61,9 +107,61 @@ type Info struct {
 	MakeExtraArgs []string
 }
 
+// GetEffectiveEnv returns the exact environment used to execute commands in the build environment.
+// In a hermetic environment, only PATH, HOME, TMPDIR, LANG and the passthrough variables are used
+// from the environment of the current process.
+func (env *Info) GetEffectiveEnv() []string {
+	var effectiveEnv []string
+	switch env.EnvMode {
+	case EnvHermetic:
+		tmpDir := os.Getenv("TMPDIR")
+		if tmpDir == "" {
+			tmpDir = os.TempDir()
+		}
+		hermeticPath := env.HermeticPath
+		if hermeticPath == "" {
+			hermeticPath = DefaultHermeticPath
+		}
+		effectiveEnv = []string{"PATH=" + hermeticPath, "HOME=" + os.Getenv("HOME"), "TMPDIR=" + tmpDir, "LANG=" + hermeticLang}
+		for _, varName := range env.EnvPassthrough {
+			value, ok := os.LookupEnv(varName)
+			if ok {
+				effectiveEnv = append(effectiveEnv, varName+"="+value)
+			}
+		}
+	default:
+		effectiveEnv = os.Environ()
+	}
+
+	// When a variable is defined more than once, the last value is used
+	effectiveEnv = append(effectiveEnv, env.Env...)
+	return dedupEnv(effectiveEnv)
+}
+
+// dedupEnv removes duplicated variables from an environment, keeping the last value of each variable
+func dedupEnv(envVars []string) []string {
+	idx := make(map[string]int)
+	var result []string
+	for _, e := range envVars {
+		name := strings.SplitN(e, "=", 2)[0]
+		if i, ok := idx[name]; ok {
+			result[i] = e
+			continue
+		}
+		idx[name] = len(result)
+		result = append(result, e)
+	}
+	return result
+}
+
 // Unpack extracts the source code from a package/tarball/zip file.
 func (env *Info) Unpack(appInfo *app.Info) error {
-	log.Println("- Unpacking software...")
+	return env.UnpackContext(context.Background(), appInfo)
+}
+
+// UnpackContext extracts the source code from a package/tarball/zip file, stopping when the context is done.
+func (env *Info) UnpackContext(ctx context.Context, appInfo *app.Info) error {
+	process.Logf(ctx, "- Unpacking software...")
 
 	// Sanity checks
 	if env.SrcPath == "" {
@@ -78,7 +176,7 @@ func (env *Info) Unpack(appInfo *app.Info) error {
 	/*
 		if util.IsDir(env.SrcDir) {
 			// If we point to a directory, it is something like a Git checkout so nothing to do
-			log.Printf("%s does not seem to need to be unpacked (directory), skipping...", env.SrcPath)
+			process.Logf(ctx, "%s does not seem to need to be unpacked (directory), skipping...", env.SrcPath)
 			return nil
 		}
 	*/
@@ -87,7 +185,7 @@ func (env *Info) Unpack(appInfo *app.Info) error {
 	format := util.DetectTarballFormat(srcObject)
 	if format == "" {
 		// A typical use case here is a single file that just needs to be compiled
-		log.Printf("%s does not seem to need to be unpacked (unsupported format?), skipping...", env.SrcDir)
+		process.Logf(ctx, "%s does not seem to need to be unpacked (unsupported format?), skipping...", env.SrcDir)
 		return nil
 	}
 
@@ -104,12 +202,13 @@ func (env *Info) Unpack(appInfo *app.Info) error {
 	}
 
 	// Untar the package into the build directory
-	log.Printf("-> Executing from %s: %s %s %s \n", env.SrcDir, tarPath, tarArg, srcObject)
+	process.Logf(ctx, "-> Executing from %s: %s %s %s \n", env.SrcDir, tarPath, tarArg, srcObject)
 	var stdout, stderr bytes.Buffer
-	cmd := exec.Command(tarPath, tarArg, srcObject)
+	cmd := process.Command(ctx, tarPath, tarArg, srcObject)
 	cmd.Dir = env.SrcDir
-	cmd.Stderr = &stderr
-	cmd.Stdout = &stdout
+	cmd.Env = env.GetEffectiveEnv()
+	cmd.Stderr = process.Tee(ctx, &stderr)
+	cmd.Stdout = process.Tee(ctx, &stdout)
 	err = cmd.Run()
 	if err != nil {
 		return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
@@ -134,13 +233,29 @@ func (env *Info) Unpack(appInfo *app.Info) error {
 			break
 		}
 	}
-	log.Printf("-> SrcDir is now %s", env.SrcDir)
+	process.Logf(ctx, "-> SrcDir is now %s", env.SrcDir)
 
 	return nil
 }
 
 // RunMake executes the appropriate command to build the software
 func (env *Info) RunMake(sudo bool, stage string, makefilePath string, args []string) error {
+	return env.RunMakeContext(context.Background(), sudo, stage, makefilePath, args)
+}
+
+// RunMakeContext executes the appropriate command to build the software, killing make and all its
+// children when the context is done
+func (env *Info) RunMakeContext(ctx context.Context, sudo bool, stage string, makefilePath string, args []string) error {
+	var priv privilege.Strategy
+	if sudo {
+		priv.Mode = privilege.Sudo
+	}
+	return env.RunMakeAs(ctx, &priv, stage, makefilePath, args)
+}
+
+// RunMakeAs executes the appropriate command to build the software with the privileges of a given
+// strategy, killing make and all its children when the context is done
+func (env *Info) RunMakeAs(ctx context.Context, priv *privilege.Strategy, stage string, makefilePath string, args []string) error {
 	// Some sanity checks
 	if env.SrcDir == "" {
 		return fmt.Errorf("env.SrcDir is undefined")
@@ -154,27 +269,19 @@ func (env *Info) RunMake(sudo bool, stage string, makefilePath string, args []st
 	}
 
 	args = append([]string{"-j"}, args...)
-	logMsg := "make " + strings.Join(args, " ")
-	if !sudo {
-		makeCmd.BinPath = "make"
-	} else {
-		sudoBin, err := exec.LookPath("sudo")
-		logMsg = sudoBin + " " + logMsg
-		if err != nil {
-			return fmt.Errorf("failed to find the sudo binary: %s", err)
-		}
-		args = append([]string{"make"}, args...)
-		makeCmd.BinPath = sudoBin
+	args = append(args, env.MakeExtraArgs...)
+	var err error
+	makeCmd.BinPath, makeCmd.CmdArgs, err = priv.Wrap("make", args)
+	if err != nil {
+		return fmt.Errorf("unable to run make: %w", err)
 	}
-	makeCmd.CmdArgs = append(makeCmd.CmdArgs, args...)
-	makeCmd.CmdArgs = append(makeCmd.CmdArgs, env.MakeExtraArgs...)
-	log.Printf("* Executing (from %s): %s", env.SrcDir, logMsg)
+	process.Logf(ctx, "* Executing (from %s): %s %s", env.SrcDir, makeCmd.BinPath, strings.Join(makeCmd.CmdArgs, " "))
 	if len(env.Env) > 0 {
-		log.Printf("-> Using env: %s\n", env.Env)
-		makeCmd.Env = env.Env
+		process.Logf(ctx, "-> Using env: %s\n", env.Env)
 	}
+	makeCmd.Env = env.GetEffectiveEnv()
 	makeCmd.ExecDir = filepath.Dir(makefilePath)
-	res := makeCmd.Run()
+	res := process.Run(ctx, &makeCmd)
 	if res.Err != nil {
 		return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", res.Err, res.Stdout, res.Stderr)
 	}
@@ -194,7 +301,7 @@ func (env *Info) copyTarball(p *app.Info) error {
 		p.Tarball = path.Base(p.Source.URL)
 	}
 
-	targetDir := filepath.Join(env.BuildDir, p.Name)
+	targetDir := env.GetAppBuildDir(p)
 	if !util.PathExists(targetDir) {
 		err := os.MkdirAll(targetDir, defaultDirMode)
 		if err != nil {
@@ -219,7 +326,7 @@ func (env *Info) copyTarball(p *app.Info) error {
 	return nil
 }
 
-func (env *Info) gitCheckout(p *app.Info) error {
+func (env *Info) gitCheckout(ctx context.Context, p *app.Info) error {
 	// todo: should it be cached in sysCfg and passed in?
 	gitBin, err := exec.LookPath("git")
 	if err != nil {
@@ -228,7 +335,7 @@ func (env *Info) gitCheckout(p *app.Info) error {
 
 	repoName := filepath.Base(p.Source.URL)
 	repoName = strings.Replace(repoName, ".git", "", 1)
-	targetDir := filepath.Join(env.BuildDir, p.Name)
+	targetDir := env.GetAppBuildDir(p)
 	if !util.PathExists(targetDir) {
 		err = os.MkdirAll(targetDir, defaultDirMode)
 		if err != nil {
@@ -238,23 +345,30 @@ func (env *Info) gitCheckout(p *app.Info) error {
 	checkoutPath := filepath.Join(targetDir, repoName)
 
 	if util.PathExists(checkoutPath) {
-		gitCmd := exec.Command(gitBin, "pull")
-		log.Printf("Running from %s: %s pull\n", checkoutPath, gitBin)
+		// A specific commit is checked out in a detached state, where pulling is not possible
+		gitSubCmd := "pull"
+		if p.Source.Commit != "" {
+			gitSubCmd = "fetch"
+		}
+		gitCmd := process.Command(ctx, gitBin, gitSubCmd)
+		process.Logf(ctx, "Running from %s: %s %s\n", checkoutPath, gitBin, gitSubCmd)
 		gitCmd.Dir = checkoutPath
+		gitCmd.Env = env.GetEffectiveEnv()
 		var stderr, stdout bytes.Buffer
-		gitCmd.Stderr = &stderr
-		gitCmd.Stdout = &stdout
+		gitCmd.Stderr = process.Tee(ctx, &stderr)
+		gitCmd.Stdout = process.Tee(ctx, &stdout)
 		err = gitCmd.Run()
 		if err != nil {
 			return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
 		}
 	} else {
-		gitCloneCmd := exec.Command(gitBin, "clone", p.Source.URL)
-		log.Printf("Running from %s: %s clone %s\n", env.BuildDir, gitBin, p.Source.URL)
+		gitCloneCmd := process.Command(ctx, gitBin, "clone", p.Source.URL)
+		process.Logf(ctx, "Running from %s: %s clone %s\n", env.BuildDir, gitBin, p.Source.URL)
 		gitCloneCmd.Dir = targetDir
+		gitCloneCmd.Env = env.GetEffectiveEnv()
 		var stderr, stdout bytes.Buffer
-		gitCloneCmd.Stderr = &stderr
-		gitCloneCmd.Stdout = &stdout
+		gitCloneCmd.Stderr = process.Tee(ctx, &stderr)
+		gitCloneCmd.Stdout = process.Tee(ctx, &stdout)
 		err = gitCloneCmd.Run()
 		if err != nil {
 			return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
@@ -267,23 +381,25 @@ func (env *Info) gitCheckout(p *app.Info) error {
 				return fmt.Errorf("unable to run prelude before checking out the branch, cannot find %s", tokens[0])
 			}
 
-			gitCheckoutPreludeCmd := exec.Command(cmdBin, tokens[1:]...)
-			log.Printf("Running from %s: %s %s\n", env.BuildDir, cmdBin, strings.Join(tokens[1:], " "))
+			gitCheckoutPreludeCmd := process.Command(ctx, cmdBin, tokens[1:]...)
+			process.Logf(ctx, "Running from %s: %s %s\n", env.BuildDir, cmdBin, strings.Join(tokens[1:], " "))
 			gitCheckoutPreludeCmd.Dir = filepath.Join(targetDir, repoName)
-			gitCheckoutPreludeCmd.Stderr = &stderr
-			gitCheckoutPreludeCmd.Stdout = &stdout
+			gitCheckoutPreludeCmd.Env = env.GetEffectiveEnv()
+			gitCheckoutPreludeCmd.Stderr = process.Tee(ctx, &stderr)
+			gitCheckoutPreludeCmd.Stdout = process.Tee(ctx, &stdout)
 			err = gitCheckoutPreludeCmd.Run()
 			if err != nil {
 				return fmt.Errorf("command failed: %s - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
 			}
 		}
 
-		if p.Source.Branch != "" {
-			gitCheckoutCmd := exec.Command(gitBin, "checkout", p.Source.Branch)
-			log.Printf("Running from %s: %s checkout %s\n", env.BuildDir, gitBin, p.Source.Branch)
+		if p.Source.Branch != "" && p.Source.Commit == "" {
+			gitCheckoutCmd := process.Command(ctx, gitBin, "checkout", p.Source.Branch)
+			process.Logf(ctx, "Running from %s: %s checkout %s\n", env.BuildDir, gitBin, p.Source.Branch)
 			gitCheckoutCmd.Dir = filepath.Join(targetDir, repoName)
-			gitCheckoutCmd.Stderr = &stderr
-			gitCheckoutCmd.Stdout = &stdout
+			gitCheckoutCmd.Env = env.GetEffectiveEnv()
+			gitCheckoutCmd.Stderr = process.Tee(ctx, &stderr)
+			gitCheckoutCmd.Stdout = process.Tee(ctx, &stdout)
 			err = gitCheckoutCmd.Run()
 			if err != nil {
 				return fmt.Errorf("command failed: %s - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
@@ -291,6 +407,20 @@ func (env *Info) gitCheckout(p *app.Info) error {
 		}
 	}
 
+	if p.Source.Commit != "" {
+		gitCheckoutCmd := process.Command(ctx, gitBin, "checkout", p.Source.Commit)
+		process.Logf(ctx, "Running from %s: %s checkout %s\n", checkoutPath, gitBin, p.Source.Commit)
+		gitCheckoutCmd.Dir = checkoutPath
+		gitCheckoutCmd.Env = env.GetEffectiveEnv()
+		var stderr, stdout bytes.Buffer
+		gitCheckoutCmd.Stderr = process.Tee(ctx, &stderr)
+		gitCheckoutCmd.Stdout = process.Tee(ctx, &stdout)
+		err = gitCheckoutCmd.Run()
+		if err != nil {
+			return fmt.Errorf("command failed: %s - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+		}
+	}
+
 	// Both env.SrcPath and env.SrcDir are set to the directory checkout because:
 	// - the value of SrcPath will make the code figure out in a safe manner that it is not necessary to do unpack
 	// - the value of SrcDir will point to where the code is from configuration/compilation/installation
@@ -300,9 +430,29 @@ func (env *Info) gitCheckout(p *app.Info) error {
 	return nil
 }
 
+// FileDigest returns the digest of a file, with the "sha256:<hex>" format
+func FileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
 // Get is the function to get a given source code
 func (env *Info) Get(p *app.Info) error {
-	log.Printf("- Getting %s from %s...\n", p.Name, p.Source.URL)
+	return env.GetContext(context.Background(), p)
+}
+
+// GetContext is the function to get a given source code, stopping when the context is done
+func (env *Info) GetContext(ctx context.Context, p *app.Info) error {
+	process.Logf(ctx, "- Getting %s from %s...\n", p.Name, p.Source.URL)
 
 	// Sanity checks
 	if p.Source.URL == "" {
@@ -326,7 +476,7 @@ func (env *Info) Get(p *app.Info) error {
 		} else {
 			// If we deal with a directory, we always copy it directly to the build directory because
 			// it is a pain to safely cache
-			targetDir := filepath.Join(env.BuildDir, p.Name)
+			targetDir := env.GetAppBuildDir(p)
 			if !util.PathExists(targetDir) {
 				err := os.MkdirAll(targetDir, 0755)
 				if err != nil {
@@ -342,7 +492,8 @@ func (env *Info) Get(p *app.Info) error {
 			cmd.CmdArgs = append(cmd.CmdArgs, "-rf")
 			cmd.CmdArgs = append(cmd.CmdArgs, path)
 			cmd.CmdArgs = append(cmd.CmdArgs, targetDir)
-			res := cmd.Run()
+			cmd.Env = env.GetEffectiveEnv()
+			res := process.Run(ctx, &cmd)
 			if res.Err != nil {
 				return fmt.Errorf("unable to copy %s into %s: %w, stdout: %s, stderr: %s", path, targetDir, res.Err, res.Stdout, res.Stderr)
 			}
@@ -351,7 +502,7 @@ func (env *Info) Get(p *app.Info) error {
 			env.SrcDir = env.SrcPath
 		}
 	case util.HttpURL:
-		err := env.download(p)
+		err := env.download(ctx, p)
 		if err != nil {
 			return fmt.Errorf("env.download() failed, impossible to download %s: %w", p.Name, err)
 		}
@@ -359,7 +510,7 @@ func (env *Info) Get(p *app.Info) error {
 		// If we deal with a Git repository, we always clone it in the build directory because
 		// it is a pain to safely cache
 		env.SrcPath = env.BuildDir
-		err := env.gitCheckout(p)
+		err := env.gitCheckout(ctx, p)
 		if err != nil {
 			return fmt.Errorf("impossible to get Git repository %s: %s", p.Source.URL, err)
 		}
@@ -367,10 +518,23 @@ func (env *Info) Get(p *app.Info) error {
 		return fmt.Errorf("impossible to detect URL type: %s", p.Source.URL)
 	}
 
+	if p.Source.Digest != "" {
+		if util.IsDir(env.SrcPath) {
+			return fmt.Errorf("unable to check the digest of %s: %s is not a file", p.Name, env.SrcPath)
+		}
+		digest, err := FileDigest(env.SrcPath)
+		if err != nil {
+			return err
+		}
+		if digest != p.Source.Digest {
+			return fmt.Errorf("digest of %s is %s instead of %s", env.SrcPath, digest, p.Source.Digest)
+		}
+	}
+
 	return nil
 }
 
-func (env *Info) download(p *app.Info) error {
+func (env *Info) download(ctx context.Context, p *app.Info) error {
 	// Sanity checks
 	if p.Source.URL == "" {
 		return fmt.Errorf("p.URL is undefined")
@@ -391,9 +555,9 @@ func (env *Info) download(p *app.Info) error {
 	}
 	targetFile := filepath.Join(env.SrcDir, p.Tarball)
 	if util.FileExists(targetFile) {
-		log.Printf("- %s already exists, not downloading...", targetFile)
+		process.Logf(ctx, "- %s already exists, not downloading...", targetFile)
 	} else {
-		log.Printf("- Downloading %s from %s into %s...", p.Name, p.Source.URL, env.SrcDir)
+		process.Logf(ctx, "- Downloading %s from %s into %s...", p.Name, p.Source.URL, env.SrcDir)
 
 		// todo: do not assume wget
 		binPath, err := exec.LookPath("wget")
@@ -401,12 +565,13 @@ func (env *Info) download(p *app.Info) error {
 			return fmt.Errorf("cannot find wget: %s", err)
 		}
 
-		log.Printf("* Executing from %s: %s %s", env.SrcDir, binPath, p.Source.URL)
+		process.Logf(ctx, "* Executing from %s: %s %s", env.SrcDir, binPath, p.Source.URL)
 		var stdout, stderr bytes.Buffer
-		cmd := exec.Command(binPath, p.Source.URL)
+		cmd := process.Command(ctx, binPath, p.Source.URL)
 		cmd.Dir = env.SrcDir
-		cmd.Stderr = &stderr
-		cmd.Stdout = &stdout
+		cmd.Env = env.GetEffectiveEnv()
+		cmd.Stderr = process.Tee(ctx, &stderr)
+		cmd.Stdout = process.Tee(ctx, &stdout)
 		err = cmd.Run()
 		if err != nil {
 			return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
@@ -457,8 +622,14 @@ func (env *Info) getAppInstallDirFromURL(a *app.Info) string {
 	return ""
 }
 
+// getTargetDir returns the directory of an application within basedir. Versions of the same
+// application are side by side, e.g., <basedir>/<name>/<version>, while an application without
+// version is directly in <basedir>/<name>.
 func (env *Info) getTargetDir(basedir string, a *app.Info) string {
 	if a.Name != "" {
+		if a.Version != "" {
+			return filepath.Join(basedir, a.Name, a.Version)
+		}
 		return filepath.Join(basedir, a.Name)
 	}
 	if a.Source.URL != "" {
@@ -478,6 +649,20 @@ func (env *Info) GetAppBuildDir(a *app.Info) string {
 	return env.getTargetDir(env.BuildDir, a)
 }
 
+// GetAppStagingDir returns the full path where a specific application is staged before being moved to
+// its install directory. The staging directory is always on the same file system than the install
+// directory so the final move is atomic.
+func (env *Info) GetAppStagingDir(a *app.Info) string {
+	return env.getTargetDir(filepath.Join(env.InstallDir, stagingDirName), a)
+}
+
+// GetAppManifestStagingDir returns the directory where the manifests of the commands executed to build
+// and install a specific application are stored until the application is installed. The directory is
+// next to the build directory so it is always writable by the current user.
+func (env *Info) GetAppManifestStagingDir(a *app.Info) string {
+	return env.GetAppBuildDir(a) + manifestDirSuffix
+}
+
 // IsInstalled checks whether a specific software package is already installed in a specific build environment
 func (env *Info) IsInstalled(p *app.Info) bool {
 	installDir := env.GetAppInstallDir(p)
@@ -496,8 +681,10 @@ func (env *Info) GetEnvLDPath() string {
 	return filepath.Join(env.InstallDir, "lib") + ":" + os.Getenv("LD_LIBRARY_PATH")
 }
 
-func (env *Info) lookPath(bin string) string {
-	for _, e := range env.Env {
+// LookPath returns the full path to a binary based on the PATH of the build environment, or the
+// binary itself when it cannot be found
+func (env *Info) LookPath(bin string) string {
+	for _, e := range env.GetEffectiveEnv() {
 		envEntry := strings.Split(e, "=")
 		if envEntry[0] == "PATH" {
 			tokens := strings.Split(envEntry[1], ":")
@@ -515,23 +702,37 @@ func (env *Info) lookPath(bin string) string {
 
 // Install is a generic function to install a software
 func (env *Info) Install(p *app.Info) error {
+	return env.InstallContext(context.Background(), p)
+}
+
+// InstallContext is a generic function to install a software, stopping when the context is done
+func (env *Info) InstallContext(ctx context.Context, p *app.Info) error {
+	return env.InstallAs(ctx, new(privilege.Strategy), p)
+}
+
+// InstallAs is a generic function to install a software with the privileges of a given strategy,
+// stopping when the context is done
+func (env *Info) InstallAs(ctx context.Context, priv *privilege.Strategy, p *app.Info) error {
 	if p.InstallCmd == "" {
-		log.Println("* Application does not need installation, skipping...")
+		process.Logf(ctx, "* Application does not need installation, skipping...")
 		return nil
 	}
 
 	var cmd advexec.Advcmd
 	cmdElts := strings.Split(p.InstallCmd, " ")
-	cmd.BinPath = env.lookPath(cmdElts[0])
-	cmd.CmdArgs = cmdElts[1:]
+	var err error
+	cmd.BinPath, cmd.CmdArgs, err = priv.Wrap(env.LookPath(cmdElts[0]), cmdElts[1:])
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %w", p.Name, err)
+	}
 	cmd.ExecDir = env.SrcDir
 	cmd.ManifestName = "install"
-	cmd.ManifestDir = env.InstallDir
-	cmd.Env = env.Env
+	cmd.ManifestDir = env.GetAppManifestStagingDir(p)
+	cmd.Env = env.GetEffectiveEnv()
 
-	log.Printf("Executing from %s: %s %s.", env.SrcDir, cmd.BinPath, strings.Join(cmdElts[1:], " "))
-	log.Printf("Environment: %s\n", strings.Join(env.Env, "\n"))
-	res := cmd.Run()
+	process.Logf(ctx, "Executing from %s: %s %s.", env.SrcDir, cmd.BinPath, strings.Join(cmdElts[1:], " "))
+	process.Logf(ctx, "Environment: %s\n", strings.Join(env.Env, "\n"))
+	res := process.Run(ctx, &cmd)
 	if res.Err != nil {
 		return fmt.Errorf("failed to install %s: %s; stdout: %s; stderr: %s", p.Name, res.Err, res.Stdout, res.Stderr)
 	}
@@ -238,3 +238,87 @@ func TestGetAppInstallDir(t *testing.T) {
 		}
 	}
 }
+
+func TestGetEffectiveEnv(t *testing.T) {
+	os.Setenv("GO_SOFTWARE_BUILD_LEAK", "leak")
+	defer os.Unsetenv("GO_SOFTWARE_BUILD_LEAK")
+	os.Setenv("GO_SOFTWARE_BUILD_PASSTHROUGH", "passthrough")
+	defer os.Unsetenv("GO_SOFTWARE_BUILD_PASSTHROUGH")
+
+	tests := []struct {
+		name        string
+		env         Info
+		expected    []string
+		notExpected []string
+	}{
+		{
+			name: "inherit",
+			env: Info{
+				Env: []string{"CC=gcc"},
+			},
+			expected:    []string{"GO_SOFTWARE_BUILD_LEAK=leak", "CC=gcc"},
+			notExpected: nil,
+		},
+		{
+			name: "hermetic",
+			env: Info{
+				Env:            []string{"CC=gcc", "LANG=en_US.UTF-8"},
+				EnvMode:        EnvHermetic,
+				EnvPassthrough: []string{"GO_SOFTWARE_BUILD_PASSTHROUGH"},
+			},
+			expected:    []string{"PATH=" + DefaultHermeticPath, "GO_SOFTWARE_BUILD_PASSTHROUGH=passthrough", "CC=gcc", "LANG=en_US.UTF-8"},
+			notExpected: []string{"GO_SOFTWARE_BUILD_LEAK=leak", "LANG=" + hermeticLang},
+		},
+		{
+			name: "hermetic path",
+			env: Info{
+				EnvMode:      EnvHermetic,
+				HermeticPath: "/opt/tools/bin:/usr/bin:/bin",
+			},
+			expected:    []string{"PATH=/opt/tools/bin:/usr/bin:/bin"},
+			notExpected: []string{"PATH=" + DefaultHermeticPath},
+		},
+	}
+
+	for _, tt := range tests {
+		effectiveEnv := tt.env.GetEffectiveEnv()
+		envStr := strings.Join(effectiveEnv, "\n") + "\n"
+		for _, e := range tt.expected {
+			if !strings.Contains(envStr, e+"\n") {
+				t.Fatalf("%s: %s is missing from the effective environment", tt.name, e)
+			}
+		}
+		for _, e := range tt.notExpected {
+			if strings.Contains(envStr, e+"\n") {
+				t.Fatalf("%s: %s is unexpectedly in the effective environment", tt.name, e)
+			}
+		}
+	}
+}
+
+func TestInstallManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	env := Info{
+		SrcDir:     dir,
+		BuildDir:   filepath.Join(dir, "build"),
+		InstallDir: filepath.Join(dir, "install"),
+	}
+	p := &app.Info{Name: "helloworld", InstallCmd: "true"}
+	err = env.Install(p)
+	if err != nil {
+		t.Fatalf("unable to install: %s", err)
+	}
+	// The install directory may require privileges so the manifest is staged next to the build directory
+	manifestPath := filepath.Join(env.GetAppManifestStagingDir(p), "install.MANIFEST")
+	if !util.FileExists(manifestPath) {
+		t.Fatalf("%s does not exist", manifestPath)
+	}
+	if util.PathExists(env.InstallDir) {
+		t.Fatalf("the installation wrote in %s", env.InstallDir)
+	}
+}
@@ -9,16 +9,22 @@
 package builder
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
 	"github.com/BTMichalowicz/go_software_build/internal/pkg/autotools"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
 	"github.com/BTMichalowicz/go_software_build/pkg/app"
 	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_software_build/pkg/privilege"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
@@ -28,6 +34,47 @@ type GetConfigureExtraArgsFn func() []string
 // ConfigureFn is the function prototype to configuration a specific software
 type ConfigureFn func(*buildenv.Info, string, []string, string) error
 
+// ConfigureContextFn is the function prototype to configure a specific software, stopping when the context is done
+type ConfigureContextFn func(context.Context, *buildenv.Info, string, []string, string) error
+
+// TimeoutError is the error returned when a stage of the installation did not complete in the allocated time
+type TimeoutError struct {
+	// Stage is the stage that timed out
+	Stage Stage
+
+	// Timeout is the time that was allocated to the stage
+	Timeout time.Duration
+
+	// Err is the error returned by the stage when it was interrupted
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s stage timed out after %s: %s", e.Stage, e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// StageError is the error returned when a stage of the installation failed. Its message is the one of
+// the underlying error.
+type StageError struct {
+	// Stage is the stage that failed
+	Stage Stage
+
+	// Err is the error returned by the stage
+	Err error
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
 // Builder gathers all the data specific to a software builder
 type Builder struct {
 	// Persistent is the path where to store all the software when we need a persistent install (in opposition to temporary install)
@@ -36,11 +83,19 @@ type Builder struct {
 
 	// SudoRequired specifies if install commands needs to be executed with sudo
 	// Note that it is assumed sudo does not require a password, there is no support for interactive password management
+	// SudoRequired is ignored when Privilege is set
 	SudoRequired bool
 
+	// Privilege specifies how to elevate privileges for all the commands writing in the install directory
+	Privilege privilege.Strategy
+
 	// Configure is the function to call to configure the software
 	Configure ConfigureFn
 
+	// ConfigureContext is the function to call to configure the software when the installation can
+	// be cancelled. When set, it is used instead of Configure.
+	ConfigureContext ConfigureContextFn
+
 	// ConfigureExtraArgs is the extra arguments for the configuration command
 	ConfigureExtraArgs []string
 
@@ -52,19 +107,41 @@ type Builder struct {
 
 	// BuildScript is the script to invoke to build the package
 	BuildScript string
+
+	// RunTests specifies whether the test suite of the package, i.e., 'make check', must be executed before installing it
+	RunTests bool
+
+	// Timeouts specifies the maximum time each stage is allowed to run. Stages without timeout may run
+	// for as long as needed.
+	Timeouts map[Stage]time.Duration
+
+	// Replace specifies whether an existing installation of the software package is replaced instead
+	// of being skipped. The existing installation stays in place until the new one is complete.
+	Replace bool
 }
 
 var makefileSpellings = []string{"Makefile", "makefile"}
 
+// stagingDestDirName is the name of the directory within the staging directory used as DESTDIR
+const stagingDestDirName = "destdir"
+
 // GenericConfigure is a generic function to configure a software, basically a wrapper around autotool's configure
 func GenericConfigure(env *buildenv.Info, appName string, extraArgs []string, configurePreludeCmd string) error {
+	return GenericConfigureContext(context.Background(), env, appName, extraArgs, configurePreludeCmd)
+}
+
+// GenericConfigureContext is a generic function to configure a software, stopping when the context is done
+func GenericConfigureContext(ctx context.Context, env *buildenv.Info, appName string, extraArgs []string, configurePreludeCmd string) error {
 	var ac autotools.Config
 	ac.Install = filepath.Join(env.InstallDir, appName)
 	ac.Source = env.SrcDir
-	ac.ConfigureEnv = env.Env
+	ac.ConfigureEnv = env.GetEffectiveEnv()
 	ac.ExtraConfigureArgs = extraArgs
 	ac.ConfigurePreludeCmd = configurePreludeCmd
-	err := ac.Configure()
+	// The install directory must not exist until the software is fully installed so the manifests
+	// are stored next to the build directory until then
+	ac.ManifestDir = env.GetAppManifestStagingDir(&app.Info{Name: appName})
+	err := ac.ConfigureContext(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to configure software: %s", err)
 	}
@@ -92,9 +169,9 @@ func findMakefile(env *buildenv.Info) (string, []string, error) {
 	return "", nil, fmt.Errorf("unable to locate the Makefile")
 }
 
-func (b *Builder) compile(pkg *app.Info, env *buildenv.Info) advexec.Result {
+func (b *Builder) compile(ctx context.Context, pkg *app.Info, env *buildenv.Info) advexec.Result {
 	var res advexec.Result
-	log.Printf("- Compiling %s...\n", pkg.Name)
+	process.Logf(ctx, "- Compiling %s...\n", pkg.Name)
 
 	if b.BuildScript != "" {
 		destFile := filepath.Join(env.SrcDir, path.Base(b.BuildScript))
@@ -111,11 +188,12 @@ func (b *Builder) compile(pkg *app.Info, env *buildenv.Info) advexec.Result {
 
 			}
 		}
-		log.Printf("-> Building with %s from %s\n", destFile, env.SrcDir)
+		process.Logf(ctx, "-> Building with %s from %s\n", destFile, env.SrcDir)
 		var cmd advexec.Advcmd
 		cmd.BinPath = destFile
 		cmd.ExecDir = env.SrcDir
-		res = cmd.Run()
+		cmd.Env = env.GetEffectiveEnv()
+		res = process.Run(ctx, &cmd)
 		return res
 	}
 
@@ -127,17 +205,36 @@ func (b *Builder) compile(pkg *app.Info, env *buildenv.Info) advexec.Result {
 	pkg.AutotoolsCfg.Detect()
 	makefilePath, makeExtraArgs, err := findMakefile(env)
 	if err != nil {
-		log.Printf("-> No Makefile, trying to figure out how to compile/install %s...", pkg.Name)
+		process.Logf(ctx, "-> No Makefile, trying to figure out how to compile/install %s...", pkg.Name)
 		res.Err = fmt.Errorf("failed to figure out how to compile %s", pkg.Name)
 		return res
 	}
 
 	makefileStage := ""
-	res.Err = env.RunMake(false, makefileStage, makefilePath, makeExtraArgs)
+	res.Err = env.RunMakeContext(ctx, false, makefileStage, makefilePath, makeExtraArgs)
 	return res
 }
 
-func (b *Builder) install(pkg *app.Info, env *buildenv.Info) advexec.Result {
+func (b *Builder) test(ctx context.Context, pkg *app.Info, env *buildenv.Info) advexec.Result {
+	var res advexec.Result
+
+	if !b.RunTests {
+		process.Logf(ctx, "- Tests of %s not requested, skipping...", pkg.Name)
+		return res
+	}
+
+	makefilePath, makeExtraArgs, err := findMakefile(env)
+	if err != nil || !pkg.AutotoolsCfg.MakefileHasTarget("check", makefilePath) {
+		process.Logf(ctx, "- %s does not provide a test suite, skipping...", pkg.Name)
+		return res
+	}
+
+	process.Logf(ctx, "- Testing %s...", pkg.Name)
+	res.Err = env.RunMakeContext(ctx, false, "check", makefilePath, makeExtraArgs)
+	return res
+}
+
+func (b *Builder) install(ctx context.Context, pkg *app.Info, env *buildenv.Info) advexec.Result {
 	var res advexec.Result
 
 	if env.InstallDir == "" || env.BuildDir == "" {
@@ -145,41 +242,228 @@ func (b *Builder) install(pkg *app.Info, env *buildenv.Info) advexec.Result {
 		return res
 	}
 
-	if pkg.AutotoolsCfg.HasMakeInstall {
-		// The Makefile has a 'install' target so we just use it
-		targetDir := filepath.Join(env.InstallDir, pkg.Name)
-		if !util.PathExists(targetDir) {
-			err := os.Mkdir(targetDir, 0755)
-			if err != nil {
-				res.Err = err
-				return res
-			}
+	// Everything is first installed in a staging directory, the software is moved to its final
+	// location only once we know the installation fully succeeded
+	stagingDir := env.GetAppStagingDir(pkg)
+	destDir := filepath.Join(stagingDir, stagingDestDirName)
+	appInstallDir := env.GetAppInstallDir(pkg)
+	stagedInstallDir := filepath.Join(destDir, appInstallDir)
+
+	if pkg.InstallCmd != "" {
+		// The install command gets the staging directory through DESTDIR, like 'make install'
+		process.Logf(ctx, "- Installing %s in %s using '%s' (staged in %s)...", pkg.Name, appInstallDir, pkg.InstallCmd, destDir)
+		res.Err = b.runCustomInstallCmd(ctx, pkg, env, destDir)
+		if res.Err != nil {
+			return res
 		}
-
-		log.Printf("- Installing %s in %s using 'make install'...", pkg.Name, targetDir)
+	} else if pkg.AutotoolsCfg.HasMakeInstall {
+		// The Makefile has a 'install' target so we just use it
+		process.Logf(ctx, "- Installing %s in %s using 'make install' (staged in %s)...", pkg.Name, appInstallDir, destDir)
 		makefilePath, makeExtraArgs, err := findMakefile(env)
 		if err != nil {
 			res.Err = fmt.Errorf("unable to find Makefile: %s", err)
 			return res
 		}
-		res.Err = env.RunMake(b.SudoRequired, "install", makefilePath, makeExtraArgs)
+		makeExtraArgs = append(makeExtraArgs, "DESTDIR="+destDir)
+		res.Err = env.RunMakeAs(ctx, b.getPrivilege(), "install", makefilePath, makeExtraArgs)
+		if res.Err != nil {
+			return res
+		}
 	} else {
 		// Copy binaries and libraries to the install directory
-		log.Printf("- 'make install' not available, copying files...")
-		var cmd advexec.Advcmd
-		cmd.BinPath = "cp"
-		cmd.CmdArgs = []string{"-rf", env.GetAppBuildDir(pkg), env.InstallDir}
-		res := cmd.Run()
+		process.Logf(ctx, "- 'make install' not available, copying files...")
+		res.Err = b.runInstallCmd(ctx, "mkdir", "-p", filepath.Dir(stagedInstallDir))
+		if res.Err != nil {
+			return res
+		}
+		res.Err = b.runInstallCmd(ctx, "cp", "-rf", env.GetAppBuildDir(pkg), stagedInstallDir)
 		if res.Err != nil {
 			return res
 		}
 	}
 
+	res.Err = b.promoteStagedInstall(ctx, env, pkg, stagedInstallDir)
 	return res
 }
 
+// runCustomInstallCmd executes the install command of the software package from its source directory,
+// with the required privileges, so it installs the software under destDir
+func (b *Builder) runCustomInstallCmd(ctx context.Context, pkg *app.Info, env *buildenv.Info, destDir string) error {
+	cmdElts := strings.Fields(pkg.InstallCmd)
+	var cmd advexec.Advcmd
+	var err error
+	bin := cmdElts[0]
+	if !strings.Contains(bin, "/") {
+		bin = env.LookPath(bin)
+	}
+	priv := b.getPrivilege()
+	cmd.BinPath, cmd.CmdArgs, err = priv.Wrap(bin, cmdElts[1:])
+	if err != nil {
+		return fmt.Errorf("failed to install %s: %w", pkg.Name, err)
+	}
+	cmd.ExecDir = env.SrcDir
+	cmd.ManifestName = "install"
+	cmd.ManifestDir = env.GetAppManifestStagingDir(pkg)
+	cmd.Env = append(env.GetEffectiveEnv(), "DESTDIR="+destDir)
+	res := process.Run(ctx, &cmd)
+	if res.Err != nil {
+		return fmt.Errorf("failed to install %s: %w - stdout: %s - stderr: %s", pkg.Name, res.Err, res.Stdout, res.Stderr)
+	}
+	return nil
+}
+
+// promoteStagedInstall checks the content of a staged install and, when valid, atomically moves it to
+// its final install directory. The manifests created during the build are moved with the software.
+func (b *Builder) promoteStagedInstall(ctx context.Context, env *buildenv.Info, pkg *app.Info, stagedInstallDir string) error {
+	stagingDir := env.GetAppStagingDir(pkg)
+	appInstallDir := env.GetAppInstallDir(pkg)
+
+	if !util.IsDir(stagedInstallDir) {
+		return fmt.Errorf("the installation did not create %s, the install step may not support DESTDIR", stagedInstallDir)
+	}
+	entries, err := ioutil.ReadDir(stagedInstallDir)
+	if err != nil {
+		return fmt.Errorf("unable to read the content of %s: %w", stagedInstallDir, err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("the installation did not install anything in %s", stagedInstallDir)
+	}
+
+	// The list of installed files is saved with the other manifests so the installation can later be
+	// verified or precisely uninstalled
+	manifestDir := env.GetAppManifestStagingDir(pkg)
+	filesManifest, err := newFilesManifest(stagedInstallDir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(manifestDir, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", manifestDir, err)
+	}
+	err = writeFilesManifest(filesManifest, filepath.Join(manifestDir, filesManifestName))
+	if err != nil {
+		return err
+	}
+	manifests, err := filepath.Glob(filepath.Join(manifestDir, "*.MANIFEST"))
+	if err != nil {
+		return fmt.Errorf("unable to get the list of manifests from %s: %w", manifestDir, err)
+	}
+	if len(manifests) > 0 {
+		args := append(manifests, stagedInstallDir)
+		err := b.runInstallCmd(ctx, "cp", args...)
+		if err != nil {
+			return fmt.Errorf("unable to copy the manifests: %w", err)
+		}
+	}
+
+	previousInstallDir := ""
+	if util.PathExists(appInstallDir) {
+		if !b.Replace {
+			return fmt.Errorf("%s already exists, unable to complete the installation", appInstallDir)
+		}
+		// The previous installation is moved aside, within the staging directory, so it can be restored
+		// if the new installation cannot be moved in place
+		previousInstallDir = filepath.Join(stagingDir, "previous")
+		err = b.runInstallCmd(ctx, "rm", "-rf", previousInstallDir)
+		if err != nil {
+			return fmt.Errorf("unable to remove %s: %w", previousInstallDir, err)
+		}
+		process.Logf(ctx, "-> Moving previous installation %s to %s", appInstallDir, previousInstallDir)
+		err = b.runInstallCmd(ctx, "mv", appInstallDir, previousInstallDir)
+		if err != nil {
+			return fmt.Errorf("unable to move %s to %s: %w", appInstallDir, previousInstallDir, err)
+		}
+	}
+	err = b.runInstallCmd(ctx, "mkdir", "-p", filepath.Dir(appInstallDir))
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(appInstallDir), err)
+	}
+	process.Logf(ctx, "-> Moving %s to %s", stagedInstallDir, appInstallDir)
+	err = b.runInstallCmd(ctx, "mv", stagedInstallDir, appInstallDir)
+	if err != nil {
+		if previousInstallDir != "" {
+			restoreErr := b.runInstallCmd(ctx, "mv", previousInstallDir, appInstallDir)
+			if restoreErr != nil {
+				process.Logf(ctx, "unable to restore previous installation %s: %s", appInstallDir, restoreErr)
+			}
+		}
+		return fmt.Errorf("unable to move %s to %s: %w", stagedInstallDir, appInstallDir, err)
+	}
+
+	// From here the software is installed, anything left behind is cleaned up by the next install
+	err = os.RemoveAll(manifestDir)
+	if err != nil {
+		process.Logf(ctx, "unable to remove %s: %s", manifestDir, err)
+	}
+	err = b.runInstallCmd(ctx, "rm", "-rf", stagingDir)
+	if err != nil {
+		process.Logf(ctx, "unable to remove %s: %s", stagingDir, err)
+	}
+	// Other software may be staged at the same time so the parent directories are removed only when empty
+	for dir := filepath.Dir(stagingDir); strings.HasPrefix(dir, env.InstallDir+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if b.runInstallCmd(ctx, "rmdir", dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// writeEnvManifest records the exact environment used to execute a stage in a manifest
+func (b *Builder) writeEnvManifest(stage Stage) error {
+	manifestDir := b.Env.GetAppManifestStagingDir(&b.App)
+	if !util.PathExists(manifestDir) {
+		err := os.MkdirAll(manifestDir, 0755)
+		if err != nil {
+			return fmt.Errorf("unable to create %s: %w", manifestDir, err)
+		}
+	}
+
+	mode := string(b.Env.EnvMode)
+	if b.Env.EnvMode == buildenv.EnvInherit {
+		mode = "inherit"
+	}
+	data := []string{"Stage: " + string(stage), "Environment mode: " + mode, "Environment:"}
+	data = append(data, b.Env.GetEffectiveEnv()...)
+	manifestPath := filepath.Join(manifestDir, "env_"+string(stage)+".MANIFEST")
+	err := ioutil.WriteFile(manifestPath, []byte(strings.Join(data, "\n")+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", manifestPath, err)
+	}
+	return nil
+}
+
+// getPrivilege returns the strategy to use for all the commands that write in the install directory
+func (b *Builder) getPrivilege() *privilege.Strategy {
+	if !b.Privilege.Required() && b.SudoRequired {
+		return &privilege.Strategy{Mode: privilege.Sudo}
+	}
+	return &b.Privilege
+}
+
+// runInstallCmd executes a command that writes in the install directory, with the required privileges
+func (b *Builder) runInstallCmd(ctx context.Context, bin string, args ...string) error {
+	return b.getPrivilege().Run(ctx, "", bin, args...)
+}
+
+// Preflight checks that everything required to install the software package is available before
+// starting the installation
+func (b *Builder) Preflight(ctx context.Context) error {
+	err := b.getPrivilege().Check(ctx)
+	if err != nil {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+	return nil
+}
+
 // Install installs a software package on the host
 func (b *Builder) Install() advexec.Result {
+	return b.InstallContext(context.Background())
+}
+
+// InstallContext installs a software package on the host. When the context is done, the current stage
+// is interrupted and all the processes it started are killed.
+func (b *Builder) InstallContext(ctx context.Context) advexec.Result {
 	var res advexec.Result
 
 	// Sanity checks
@@ -192,72 +476,232 @@ func (b *Builder) Install() advexec.Result {
 		return res
 	}
 
-	log.Printf("Installing %s on host...", b.App.Name)
+	process.Logf(ctx, "Installing %s on host...", b.App.Name)
 	appInstallDir := b.Env.GetAppInstallDir(&b.App)
 	if b.Persistent != "" {
 		if b.Env.InstallDir != b.Persistent {
-			log.Printf("* Updating install directory from %s default to %s\n", b.Env.InstallDir, b.Persistent)
+			process.Logf(ctx, "* Updating install directory from %s default to %s\n", b.Env.InstallDir, b.Persistent)
 			b.Env.InstallDir = b.Persistent
 		}
 		appInstallDir = b.Env.GetAppInstallDir(&b.App)
 	}
-	if util.PathExists(appInstallDir) {
-		log.Printf("* %s already exists, skipping installation...", appInstallDir)
-		b.Env.SrcDir = appInstallDir
-		return res
+	if util.PathExists(appInstallDir) && !b.Replace {
+		// A persistent install built from different inputs than the current ones is replaced, an
+		// install that is not registered comes from somewhere else and is always kept
+		replace, err := b.persistentInstallChanged()
+		if err != nil {
+			res.Err = err
+			return res
+		}
+		if !replace {
+			process.Logf(ctx, "* %s already exists, skipping installation...", appInstallDir)
+			b.Env.SrcDir = appInstallDir
+			return res
+		}
+		process.Logf(ctx, "* %s was installed from different inputs, replacing it...", appInstallDir)
+		b.Replace = true
 	}
 
-	log.Printf("* %s does not exists, installing from scratch\n", appInstallDir)
-
-	res.Err = b.Env.Get(&b.App)
+	res.Err = b.Preflight(ctx)
 	if res.Err != nil {
-		res.Err = fmt.Errorf("failed to download software from %s: %s", b.App.Source.URL, res.Err)
 		return res
 	}
-	if b.Env.SrcPath == "" {
-		res.Err = fmt.Errorf("failed to get a path to the source")
-		return res
+
+	// A staged install is the sign of a previous installation that did not complete
+	stagedDir := filepath.Join(b.Env.GetAppStagingDir(&b.App), stagingDestDirName)
+	if util.PathExists(stagedDir) {
+		process.Logf(ctx, "* Removing %s from a previous incomplete installation", stagedDir)
+		err := b.runInstallCmd(ctx, "rm", "-rf", stagedDir)
+		if err != nil {
+			res.Err = fmt.Errorf("unable to remove %s: %w", stagedDir, err)
+			return res
+		}
 	}
 
-	res.Err = b.Env.Unpack(&b.App)
-	if res.Err != nil {
-		res.Err = fmt.Errorf("failed to unpack %s: %s", b.App.Name, res.Err)
+	state, err := b.loadState()
+	if err != nil {
+		res.Err = fmt.Errorf("unable to load the build state of %s: %w", b.App.Name, err)
 		return res
 	}
+	hashes := b.stageInputHashes()
+	firstStage := state.firstIncompleteStage(hashes)
+	if firstStage > stageIndex(StageFetch) && !util.PathExists(state.SrcDir) {
+		process.Logf(ctx, "* %s does not exist anymore, the source code must be fetched again", state.SrcDir)
+		firstStage = stageIndex(StageFetch)
+	}
+	// The install directory does not exist so the install stage always needs to be executed
+	if firstStage > stageIndex(StageInstall) {
+		firstStage = stageIndex(StageInstall)
+	}
+	state.truncate(firstStage)
 
-	b.App.AutotoolsCfg.Source = b.Env.SrcDir
-	b.App.AutotoolsCfg.Detect()
+	// Manifests from a previous configuration are stale when the software is configured again
+	manifestDir := b.Env.GetAppManifestStagingDir(&b.App)
+	if firstStage <= stageIndex(StageConfigure) && util.PathExists(manifestDir) {
+		err := os.RemoveAll(manifestDir)
+		if err != nil {
+			res.Err = fmt.Errorf("unable to remove %s: %w", manifestDir, err)
+			return res
+		}
+	}
+	if firstStage == 0 {
+		process.Logf(ctx, "* %s does not exists, installing from scratch\n", appInstallDir)
+	} else {
+		process.Logf(ctx, "* Resuming the installation of %s from the %s stage", b.App.Name, Stages[firstStage])
+		b.Env.SrcPath = state.SrcPath
+		b.Env.SrcDir = state.SrcDir
+	}
+
+	for idx := firstStage; idx < len(Stages); idx++ {
+		res = b.runStage(ctx, Stages[idx], appInstallDir)
+		if res.Err != nil {
+			return res
+		}
 
-	// Right now, we assume we do not have to install autotools, which is a bad assumption
-	var extraArgs []string
-	if len(b.App.AutotoolsCfg.ExtraConfigureArgs) > 0 {
-		extraArgs = append(extraArgs, b.App.AutotoolsCfg.ExtraConfigureArgs...)
+		state.SrcPath = b.Env.SrcPath
+		state.SrcDir = b.Env.SrcDir
+		state.Stages = append(state.Stages, stageState{Stage: Stages[idx], InputHash: hashes[idx], Completed: time.Now()})
+		err := b.saveState(state)
+		if err != nil {
+			res.Err = fmt.Errorf("unable to save the build state of %s: %w", b.App.Name, err)
+			return res
+		}
 	}
-	res.Err = b.Configure(&b.Env, b.App.Name, extraArgs, b.App.AutotoolsCfg.ConfigurePreludeCmd)
-	if res.Err != nil {
-		res.Err = fmt.Errorf("failed to configure %s: %s", b.App.Name, res.Err)
-		return res
+
+	if b.Persistent != "" {
+		res.Err = b.registerPersistentInstall(appInstallDir, hashes[len(hashes)-1])
+	}
+
+	return res
+}
+
+// runStage executes a single stage of the installation of the software package, within the time
+// allocated to the stage
+func (b *Builder) runStage(ctx context.Context, stage Stage, appInstallDir string) advexec.Result {
+	timeout := b.Timeouts[stage]
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	res = b.compile(&b.App, &b.Env)
+	res := b.execStage(ctx, stage, appInstallDir)
+	if res.Err != nil && timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		res.Err = &TimeoutError{Stage: stage, Timeout: timeout, Err: res.Err}
+	}
 	if res.Err != nil {
-		res.Stderr = fmt.Sprintf("failed to compile %s: %s", b.App.Name, res.Err)
-		return res
+		res.Err = &StageError{Stage: stage, Err: res.Err}
 	}
+	return res
+}
 
-	res = b.install(&b.App, &b.Env)
+func (b *Builder) execStage(ctx context.Context, stage Stage, appInstallDir string) advexec.Result {
+	var res advexec.Result
+
+	res.Err = b.writeEnvManifest(stage)
 	if res.Err != nil {
-		res.Stderr = fmt.Sprintf("failed to install software: %s", res.Err)
 		return res
 	}
 
+	// Stages after the unpack stage need to know what the source code provides
+	if stageIndex(stage) > stageIndex(StageUnpack) {
+		b.App.AutotoolsCfg.Source = b.Env.SrcDir
+		b.App.AutotoolsCfg.Detect()
+	}
+
+	switch stage {
+	case StageFetch:
+		res.Err = b.Env.GetContext(ctx, &b.App)
+		if res.Err != nil {
+			res.Err = fmt.Errorf("failed to download software from %s: %w", b.App.Source.URL, res.Err)
+			return res
+		}
+		if b.Env.SrcPath == "" {
+			res.Err = fmt.Errorf("failed to get a path to the source")
+			return res
+		}
+	case StageUnpack:
+		res.Err = b.Env.UnpackContext(ctx, &b.App)
+		if res.Err != nil {
+			res.Err = fmt.Errorf("failed to unpack %s: %w", b.App.Name, res.Err)
+			return res
+		}
+	case StageConfigure:
+		// Right now, we assume we do not have to install autotools, which is a bad assumption
+		var extraArgs []string
+		if len(b.App.AutotoolsCfg.ExtraConfigureArgs) > 0 {
+			extraArgs = append(extraArgs, b.App.AutotoolsCfg.ExtraConfigureArgs...)
+		}
+		// The configure functions locate the install directory from the name, which includes the
+		// version for versions installed side by side
+		appPath := b.App.Name
+		if b.App.Version != "" {
+			appPath = filepath.Join(b.App.Name, b.App.Version)
+		}
+		if b.ConfigureContext != nil {
+			res.Err = b.ConfigureContext(ctx, &b.Env, appPath, extraArgs, b.App.AutotoolsCfg.ConfigurePreludeCmd)
+		} else {
+			res.Err = b.Configure(&b.Env, appPath, extraArgs, b.App.AutotoolsCfg.ConfigurePreludeCmd)
+		}
+		if res.Err != nil {
+			res.Err = fmt.Errorf("failed to configure %s: %w", b.App.Name, res.Err)
+			return res
+		}
+	case StageBuild:
+		res = b.compile(ctx, &b.App, &b.Env)
+		if res.Err != nil {
+			res.Stderr = fmt.Sprintf("failed to compile %s: %s", b.App.Name, res.Err)
+			return res
+		}
+	case StageTest:
+		res = b.test(ctx, &b.App, &b.Env)
+		if res.Err != nil {
+			res.Stderr = fmt.Sprintf("failed to test %s: %s", b.App.Name, res.Err)
+			return res
+		}
+	case StageInstall:
+		res = b.install(ctx, &b.App, &b.Env)
+		if res.Err != nil {
+			res.Stderr = fmt.Sprintf("failed to install software: %s", res.Err)
+			// The install directory did not exist when we started so anything in there is from an
+			// incomplete installation that must not be mistaken for a valid one. When replacing an
+			// installation, the previous one is left untouched until the new one is complete.
+			if util.PathExists(appInstallDir) && !b.Replace {
+				err := b.runInstallCmd(ctx, "rm", "-rf", appInstallDir)
+				if err != nil {
+					process.Logf(ctx, "unable to remove incomplete installation %s: %s", appInstallDir, err)
+				}
+			}
+			return res
+		}
+	default:
+		res.Err = fmt.Errorf("unknown stage: %s", stage)
+	}
+
 	return res
 }
 
 // Uninstall uninstalls a version of software from the host that was previously installed by our tool
 func (b *Builder) Uninstall() advexec.Result {
+	return b.UninstallContext(context.Background())
+}
+
+// UninstallContext uninstalls a version of software from the host that was previously installed by our
+// tool. When the installation has a manifest of the files it created, exactly these files are removed;
+// otherwise the entire install directory is removed. In persistent mode, only software packages from
+// the registry of the persistent directory are uninstalled.
+func (b *Builder) UninstallContext(ctx context.Context) advexec.Result {
 	var res advexec.Result
 	if b.Persistent == "" {
+		if util.FileExists(filepath.Join(b.Env.GetAppInstallDir(&b.App), filesManifestName)) {
+			manifest, err := b.LoadFilesManifest()
+			if err != nil {
+				res.Err = err
+				return res
+			}
+			res.Err = b.uninstallFiles(ctx, manifest)
+			return res
+		}
 		if util.PathExists(b.Env.InstallDir) {
 			err := os.RemoveAll(b.Env.InstallDir)
 			if err != nil {
@@ -266,17 +710,53 @@ func (b *Builder) Uninstall() advexec.Result {
 			}
 		}
 	} else {
-		log.Printf("Persistent installs mode, not uninstalling software from host")
+		res.Err = b.uninstallPersistent(ctx)
 	}
 
 	return res
 }
 
+// Remove deletes the installation of the software package, along with its staging directory, its
+// build directory and its build state, so the next installation starts from scratch. Unlike Uninstall,
+// nothing else from the install directory is removed.
+func (b *Builder) Remove(ctx context.Context) error {
+	if b.Persistent != "" {
+		b.Env.InstallDir = b.Persistent
+	}
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	stagingDir := b.Env.GetAppStagingDir(&b.App)
+	for _, dir := range []string{appInstallDir, stagingDir} {
+		if util.PathExists(dir) {
+			process.Logf(ctx, "-> Removing %s", dir)
+			err := b.runInstallCmd(ctx, "rm", "-rf", dir)
+			if err != nil {
+				return fmt.Errorf("unable to remove %s: %w", dir, err)
+			}
+		}
+	}
+
+	for _, path := range []string{b.Env.GetAppBuildDir(&b.App), b.stateFilePath()} {
+		if util.PathExists(path) {
+			process.Logf(ctx, "-> Removing %s", path)
+			err := os.RemoveAll(path)
+			if err != nil {
+				return fmt.Errorf("unable to remove %s: %w", path, err)
+			}
+		}
+	}
+
+	if b.Persistent != "" {
+		return b.unregisterPersistentInstall()
+	}
+	return nil
+}
+
 // Load is the function that will figure out the function to call for various stages of the code configuration/compilation/installation/execution
 func (b *Builder) Load(persistent bool) error {
 	// fixme: at this point, we know the app and we have the builder object
 	// so we should be able to do a autodetect instead of forcing autotools
 	b.Configure = GenericConfigure
+	b.ConfigureContext = GenericConfigureContext
 
 	if b.App.Name == "" {
 		return fmt.Errorf("application's name is undefined")
@@ -303,6 +783,11 @@ func (b *Builder) Load(persistent bool) error {
 
 // Compile compiles and installs a given application on the host
 func (b *Builder) Compile() error {
+	return b.CompileContext(context.Background())
+}
+
+// CompileContext compiles and installs a given application on the host, stopping when the context is done
+func (b *Builder) CompileContext(ctx context.Context) error {
 	// The builder has a general environment (set by caller) but we need a detailed
 	// environment specific to the app
 	var buildEnv buildenv.Info
@@ -323,24 +808,24 @@ func (b *Builder) Compile() error {
 		}
 	}
 
-	log.Printf("Build the application in %s\n", buildEnv.BuildDir)
-	log.Printf("Install the application in %s\n", buildEnv.InstallDir)
+	process.Logf(ctx, "Build the application in %s\n", buildEnv.BuildDir)
+	process.Logf(ctx, "Install the application in %s\n", buildEnv.InstallDir)
 
 	// Download the app
-	err := buildEnv.Get(&b.App)
+	err := buildEnv.GetContext(ctx, &b.App)
 	if err != nil {
 		return fmt.Errorf("unable to get the application from %s: %s", b.App.Source.URL, err)
 	}
 
 	// Unpacking the app
-	err = buildEnv.Unpack(&b.App)
+	err = buildEnv.UnpackContext(ctx, &b.App)
 	if err != nil {
 		return fmt.Errorf("unable to unpack the application %s: %s", buildEnv.SrcPath, err)
 	}
 
 	// Install the app
-	log.Println("-> Building the application...")
-	err = buildEnv.Install(&b.App)
+	process.Logf(ctx, "-> Building the application...")
+	err = buildEnv.InstallAs(ctx, b.getPrivilege(), &b.App)
 	if err != nil {
 		return fmt.Errorf("unable to install package: %s", err)
 	}
@@ -349,7 +834,7 @@ func (b *Builder) Compile() error {
 	// if we must just use the binary in BuildDir. For now we assume that we use the binary in
 	// BuildDir.
 	b.App.BinPath = filepath.Join(buildEnv.SrcDir, b.App.BinName)
-	log.Printf("-> Successfully created %s\n", b.App.BinPath)
+	process.Logf(ctx, "-> Successfully created %s\n", b.App.BinPath)
 
 	return nil
 }
@@ -6,13 +6,19 @@
 package builder
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/persistent"
+	"github.com/BTMichalowicz/go_software_build/pkg/privilege"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
@@ -55,6 +61,58 @@ func setBuilder(t *testing.T) (*Builder, func()) {
 	return b, cleanupFn
 }
 
+const (
+	localConfigureScript = `#!/bin/sh
+prefix=/usr/local
+while [ $# -gt 0 ]; do
+	case "$1" in
+		--prefix) prefix="$2"; shift ;;
+	esac
+	shift
+done
+sed -e "s#@PREFIX@#$prefix#" Makefile.in > Makefile
+`
+
+	localMakefile = `PREFIX=@PREFIX@
+
+all:
+	@BUILD_HOOK@
+	printf '#!/bin/sh\necho hello\n' > helloworld
+	chmod +x helloworld
+
+install:
+	mkdir -p $(DESTDIR)$(PREFIX)/bin
+	@INSTALL_HOOK@
+	cp helloworld $(DESTDIR)$(PREFIX)/bin/helloworld
+`
+)
+
+// createLocalSoftware creates a minimal autotools-like software package that can be built without
+// network access. When buildHook or installHook are not empty, they are respectively executed at the
+// beginning of the build and before the binary is installed.
+func createLocalSoftware(t *testing.T, buildHook string, installHook string) string {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	srcDir := filepath.Join(dir, "helloworld")
+	err = os.MkdirAll(srcDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", srcDir, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(srcDir, "configure"), []byte(localConfigureScript), 0755)
+	if err != nil {
+		t.Fatalf("unable to create configure script: %s", err)
+	}
+	makefile := strings.Replace(localMakefile, "@INSTALL_HOOK@", installHook, 1)
+	makefile = strings.Replace(makefile, "@BUILD_HOOK@", buildHook, 1)
+	err = ioutil.WriteFile(filepath.Join(srcDir, "Makefile.in"), []byte(makefile), 0644)
+	if err != nil {
+		t.Fatalf("unable to create Makefile.in: %s", err)
+	}
+	return dir
+}
+
 func TestInstallFromAutotoolsRelease(t *testing.T) {
 	b, cleanupFn := setBuilder(t)
 	defer cleanupFn()
@@ -143,6 +201,8 @@ func TestInstallFromSource(t *testing.T) {
 }
 
 func TestPersistentBuildFromLocalTarball(t *testing.T) {
+	// The registry of the persistent directory is kept in the cache directory of the user
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
 	url := "https://github.com/BTMichalowicz/c_hello_world/archive/1.0.0.tar.gz"
 	tarballFilename := "1.0.0.tar.gz"
 	wgetBin, err := exec.LookPath("wget")
@@ -207,3 +267,329 @@ func TestPersistentBuildFromLocalTarball(t *testing.T) {
 		t.Fatalf("expected tarball is missing: %s instead of %s", b.Env.SrcPath, expectedTarball)
 	}
 }
+
+func TestStagedInstall(t *testing.T) {
+	srcDir := createLocalSoftware(t, "", "")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+
+	res := b.Install()
+	if res.Err != nil {
+		t.Fatalf("unable to install the software package: %s", res.Err)
+	}
+
+	expectedBinary := filepath.Join(b.Env.InstallDir, b.App.Name, "bin", "helloworld")
+	if !util.FileExists(expectedBinary) {
+		t.Fatalf("expected binary %s does not exist", expectedBinary)
+	}
+	for _, manifest := range []string{"configure.MANIFEST", "env_build.MANIFEST"} {
+		expectedManifest := filepath.Join(b.Env.InstallDir, b.App.Name, manifest)
+		if !util.FileExists(expectedManifest) {
+			t.Fatalf("expected manifest %s does not exist", expectedManifest)
+		}
+	}
+	stagingDir := b.Env.GetAppStagingDir(&b.App)
+	if util.PathExists(stagingDir) {
+		t.Fatalf("staging directory %s was not removed", stagingDir)
+	}
+}
+
+func TestFilesManifest(t *testing.T) {
+	srcDir := createLocalSoftware(t, "", "mkdir -p $(DESTDIR)$(PREFIX)/share && echo hello > $(DESTDIR)$(PREFIX)/share/README && ln -s README $(DESTDIR)$(PREFIX)/share/README.link")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+	res := b.Install()
+	if res.Err != nil {
+		t.Fatalf("unable to install the software package: %s", res.Err)
+	}
+
+	manifest, err := b.LoadFilesManifest()
+	if err != nil {
+		t.Fatalf("unable to load the manifest of the installed files: %s", err)
+	}
+	var paths []string
+	for _, file := range manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	if strings.Join(paths, " ") != "bin/helloworld share/README share/README.link" {
+		t.Fatalf("invalid list of installed files: %s", paths)
+	}
+	err = b.Verify()
+	if err != nil {
+		t.Fatalf("unable to verify the installation: %s", err)
+	}
+
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	err = ioutil.WriteFile(filepath.Join(appInstallDir, "bin", "helloworld"), []byte("modified"), 0755)
+	if err != nil {
+		t.Fatalf("unable to modify the installed binary: %s", err)
+	}
+	err = os.Remove(filepath.Join(appInstallDir, "share", "README.link"))
+	if err != nil {
+		t.Fatalf("unable to remove the installed link: %s", err)
+	}
+	err = b.Verify()
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) || strings.Join(verifyErr.Modified, " ") != "bin/helloworld" || strings.Join(verifyErr.Missing, " ") != "share/README.link" {
+		t.Fatalf("verification did not detect the modified and missing files: %v", err)
+	}
+
+	// Files that were not installed by the software package are left in place
+	userFile := filepath.Join(appInstallDir, "bin", "user.conf")
+	err = ioutil.WriteFile(userFile, []byte("user"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", userFile, err)
+	}
+	res = b.Uninstall()
+	if res.Err != nil {
+		t.Fatalf("unable to uninstall the software package: %s", res.Err)
+	}
+	if !util.FileExists(userFile) {
+		t.Fatalf("%s was removed by the uninstallation", userFile)
+	}
+	for _, path := range []string{filepath.Join(appInstallDir, "bin", "helloworld"), filepath.Join(appInstallDir, "share"), filepath.Join(appInstallDir, filesManifestName), filepath.Join(appInstallDir, "configure.MANIFEST")} {
+		if util.PathExists(path) {
+			t.Fatalf("%s still exists after the uninstallation", path)
+		}
+	}
+}
+
+func TestPersistentRegistry(t *testing.T) {
+	// The registry of the persistent directory is kept in the cache directory of the user
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	srcDir := createLocalSoftware(t, "", "")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.Persistent = b.Env.InstallDir
+	b.App.Name = "helloworld"
+	b.App.Version = "1.0"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(true)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+	registry := persistent.NewRegistry(b.Persistent)
+	install := func() *persistent.Entry {
+		res := b.Install()
+		if res.Err != nil {
+			t.Fatalf("unable to install the software package: %s", res.Err)
+		}
+		entry, err := registry.Get(b.App.Name, b.App.Version)
+		if err != nil || entry == nil {
+			t.Fatalf("unable to get the software package from the registry: %v", err)
+		}
+		return entry
+	}
+
+	entry := install()
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	if entry.InstallDir != appInstallDir || entry.URL != b.App.Source.URL || entry.InputHash == "" {
+		t.Fatalf("invalid registry entry: %+v", entry)
+	}
+
+	// The installation is reused as long as the inputs do not change
+	if reused := install(); !reused.BuildTime.Equal(entry.BuildTime) {
+		t.Fatalf("the software package was installed again while its inputs did not change")
+	}
+	b.App.AutotoolsCfg.ExtraConfigureArgs = []string{"--enable-debug"}
+	if replaced := install(); replaced.BuildTime.Equal(entry.BuildTime) || replaced.InputHash == entry.InputHash {
+		t.Fatalf("the software package was not installed again while its inputs changed")
+	}
+
+	res := b.Uninstall()
+	if res.Err != nil {
+		t.Fatalf("unable to uninstall the software package: %s", res.Err)
+	}
+	if util.PathExists(filepath.Join(appInstallDir, "bin", "helloworld")) {
+		t.Fatalf("the software package is still installed in %s", appInstallDir)
+	}
+	entries, err := registry.List()
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("the registry is not empty after the uninstallation (%v): %+v", err, entries)
+	}
+}
+
+func TestInterruptedInstall(t *testing.T) {
+	srcDir := createLocalSoftware(t, "", "exit 1")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+
+	res := b.Install()
+	if res.Err == nil {
+		t.Fatalf("install succeeded while the install target fails")
+	}
+	var stageErr *StageError
+	if !errors.As(res.Err, &stageErr) || stageErr.Stage != StageInstall {
+		t.Fatalf("error does not report the install stage as the failing stage: %s", res.Err)
+	}
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	if util.PathExists(appInstallDir) {
+		t.Fatalf("%s exists after a failed installation", appInstallDir)
+	}
+}
+
+func TestResumeInstall(t *testing.T) {
+	srcDir := createLocalSoftware(t, "", "exit 1")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+
+	res := b.Install()
+	if res.Err == nil {
+		t.Fatalf("install succeeded while the install target fails")
+	}
+
+	// Fix the install target and make sure configure cannot be executed again, the build is
+	// then expected to resume from the install stage
+	buildSrcDir := b.Env.SrcDir
+	makefilePath := filepath.Join(buildSrcDir, "Makefile")
+	content, err := ioutil.ReadFile(makefilePath)
+	if err != nil {
+		t.Fatalf("unable to read %s: %s", makefilePath, err)
+	}
+	err = ioutil.WriteFile(makefilePath, []byte(strings.Replace(string(content), "exit 1", "", 1)), 0644)
+	if err != nil {
+		t.Fatalf("unable to write %s: %s", makefilePath, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(buildSrcDir, "configure"), []byte("#!/bin/sh\nexit 1\n"), 0755)
+	if err != nil {
+		t.Fatalf("unable to overwrite configure: %s", err)
+	}
+
+	res = b.Install()
+	if res.Err != nil {
+		t.Fatalf("unable to resume the installation: %s", res.Err)
+	}
+	expectedBinary := filepath.Join(b.Env.InstallDir, b.App.Name, "bin", "helloworld")
+	if !util.FileExists(expectedBinary) {
+		t.Fatalf("expected binary %s does not exist", expectedBinary)
+	}
+
+	err = b.RestartFrom(Stage("dummy"))
+	if err == nil {
+		t.Fatalf("restarting from an unknown stage succeeded")
+	}
+	err = b.RestartFrom(StageConfigure)
+	if err != nil {
+		t.Fatalf("unable to restart from %s: %s", StageConfigure, err)
+	}
+	state, err := b.loadState()
+	if err != nil {
+		t.Fatalf("unable to load the build state: %s", err)
+	}
+	if len(state.Stages) != stageIndex(StageConfigure) {
+		t.Fatalf("state has %d completed stages instead of %d", len(state.Stages), stageIndex(StageConfigure))
+	}
+}
+
+func TestStageTimeout(t *testing.T) {
+	srcDir := createLocalSoftware(t, "sleep 60", "")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err := b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+	b.Timeouts = map[Stage]time.Duration{StageBuild: time.Second}
+
+	start := time.Now()
+	res := b.InstallContext(context.Background())
+	if res.Err == nil {
+		t.Fatalf("install succeeded while the build is expected to time out")
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(res.Err, &timeoutErr) {
+		t.Fatalf("install failed with %s instead of a timeout", res.Err)
+	}
+	if timeoutErr.Stage != StageBuild {
+		t.Fatalf("%s stage timed out instead of %s", timeoutErr.Stage, StageBuild)
+	}
+	if time.Since(start) > 30*time.Second {
+		t.Fatalf("the build was not interrupted in time")
+	}
+}
+
+func TestPrivilegeWrapper(t *testing.T) {
+	srcDir := createLocalSoftware(t, "", "")
+	defer os.RemoveAll(srcDir)
+	b, cleanupFn := setBuilder(t)
+	defer cleanupFn()
+
+	// The wrapper logs all the commands it executes so we can check that all the install commands go through it
+	wrapperLog := filepath.Join(srcDir, "wrapper.log")
+	wrapperPath := filepath.Join(srcDir, "wrapper.sh")
+	err := ioutil.WriteFile(wrapperPath, []byte("#!/bin/sh\necho \"$1\" >> "+wrapperLog+"\nexec \"$@\"\n"), 0755)
+	if err != nil {
+		t.Fatalf("unable to create the wrapper: %s", err)
+	}
+
+	b.App.Name = "helloworld"
+	b.App.Source.URL = "file://" + filepath.Join(srcDir, "helloworld")
+	err = b.Load(false)
+	if err != nil {
+		t.Fatalf("unable to load the builder: %s", err)
+	}
+
+	b.Privilege = privilege.Strategy{Mode: privilege.Wrapper, Wrapper: []string{"false"}}
+	res := b.Install()
+	if res.Err == nil {
+		t.Fatalf("install succeeded while privileges cannot be elevated")
+	}
+
+	b.Privilege = privilege.Strategy{Mode: privilege.Wrapper, Wrapper: []string{wrapperPath}}
+	res = b.Install()
+	if res.Err != nil {
+		t.Fatalf("unable to install the software package: %s", res.Err)
+	}
+	expectedBinary := filepath.Join(b.Env.InstallDir, b.App.Name, "bin", "helloworld")
+	if !util.FileExists(expectedBinary) {
+		t.Fatalf("expected binary %s does not exist", expectedBinary)
+	}
+
+	content, err := ioutil.ReadFile(wrapperLog)
+	if err != nil {
+		t.Fatalf("unable to read %s: %s", wrapperLog, err)
+	}
+	for _, cmd := range []string{"true", "make", "mv"} {
+		if !strings.Contains(string(content), cmd+"\n") {
+			t.Fatalf("%s was not executed through the wrapper: %s", cmd, string(content))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package builder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// filesManifestName is the name of the manifest, saved with the other manifests in the install
+	// directory, listing all the files the installation created
+	filesManifestName = "files.MANIFEST"
+
+	// removeBatchSize is the maximum number of files removed with a single command
+	removeBatchSize = 256
+)
+
+// InstalledFile is a file created by the installation of a software package
+type InstalledFile struct {
+	// Path is the path of the file relative to the install directory of the software package
+	Path string `json:"path"`
+
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+
+	// Mode is the mode and permissions of the file
+	Mode os.FileMode `json:"mode"`
+
+	// SHA256 is the hash of the content of the file, empty for symbolic links
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Link is the target of the file when it is a symbolic link
+	Link string `json:"link,omitempty"`
+}
+
+// FilesManifest is the list of all the files created by the installation of a software package
+type FilesManifest struct {
+	Files []InstalledFile `json:"files"`
+}
+
+// VerifyError is the error returned when the files of an installation do not match its manifest
+type VerifyError struct {
+	// Missing is the path of the files that no longer exist
+	Missing []string
+
+	// Modified is the path of the files whose content, size, mode or link target changed
+	Modified []string
+}
+
+func (e *VerifyError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing files: "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Modified) > 0 {
+		problems = append(problems, "modified files: "+strings.Join(e.Modified, ", "))
+	}
+	return strings.Join(problems, "; ")
+}
+
+// describeFile returns the description of a file, relPath being its path relative to the install directory
+func describeFile(path string, relPath string) (*InstalledFile, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &InstalledFile{Path: relPath, Size: info.Size(), Mode: info.Mode()}
+	if info.Mode()&os.ModeSymlink != 0 {
+		file.Link, err = os.Readlink(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read link %s: %w", path, err)
+		}
+		return file, nil
+	}
+	digest, err := buildenv.FileDigest(path)
+	if err != nil {
+		return nil, err
+	}
+	file.SHA256 = strings.TrimPrefix(digest, "sha256:")
+	return file, nil
+}
+
+// newFilesManifest returns the manifest of all the files, other than directories, in a directory
+func newFilesManifest(dir string) (*FilesManifest, error) {
+	manifest := new(FilesManifest)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		file, err := describeFile(path, relPath)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, *file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the files of %s: %w", dir, err)
+	}
+	return manifest, nil
+}
+
+func writeFilesManifest(manifest *FilesManifest, path string) error {
+	content, err := json.MarshalIndent(manifest, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the manifest of the installed files: %w", err)
+	}
+	err = ioutil.WriteFile(path, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadFilesManifest returns the list of the files created by the installation of the software package
+func (b *Builder) LoadFilesManifest() (*FilesManifest, error) {
+	path := filepath.Join(b.Env.GetAppInstallDir(&b.App), filesManifestName)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	manifest := new(FilesManifest)
+	err = json.Unmarshal(content, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Verify checks that all the files created by the installation of the software package still exist
+// and were not modified. A *VerifyError is returned when some files are missing or modified.
+func (b *Builder) Verify() error {
+	manifest, err := b.LoadFilesManifest()
+	if err != nil {
+		return err
+	}
+
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	verifyErr := new(VerifyError)
+	for _, expected := range manifest.Files {
+		path := filepath.Join(appInstallDir, expected.Path)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			verifyErr.Missing = append(verifyErr.Missing, expected.Path)
+			continue
+		}
+		actual, err := describeFile(path, expected.Path)
+		if err != nil {
+			return fmt.Errorf("unable to verify %s: %w", path, err)
+		}
+		if *actual != expected {
+			verifyErr.Modified = append(verifyErr.Modified, expected.Path)
+		}
+	}
+	if len(verifyErr.Missing) > 0 || len(verifyErr.Modified) > 0 {
+		return verifyErr
+	}
+	return nil
+}
+
+// uninstallFiles removes exactly the files listed in the manifest of the installation, the manifests,
+// and the directories left empty. Anything else added to the install directory is left in place.
+func (b *Builder) uninstallFiles(ctx context.Context, manifest *FilesManifest) error {
+	appInstallDir := b.Env.GetAppInstallDir(&b.App)
+	manifests, err := filepath.Glob(filepath.Join(appInstallDir, "*.MANIFEST"))
+	if err != nil {
+		return fmt.Errorf("unable to get the list of manifests from %s: %w", appInstallDir, err)
+	}
+
+	var paths []string
+	dirs := map[string]bool{appInstallDir: true}
+	for _, file := range manifest.Files {
+		path := filepath.Join(appInstallDir, file.Path)
+		paths = append(paths, path)
+		for dir := filepath.Dir(path); dir != appInstallDir && strings.HasPrefix(dir, appInstallDir); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	paths = append(paths, manifests...)
+
+	process.Logf(ctx, "-> Removing the %d files installed in %s", len(paths), appInstallDir)
+	for len(paths) > 0 {
+		batch := paths
+		if len(batch) > removeBatchSize {
+			batch = paths[:removeBatchSize]
+		}
+		err := b.runInstallCmd(ctx, "rm", append([]string{"-f"}, batch...)...)
+		if err != nil {
+			return fmt.Errorf("unable to remove the installed files: %w", err)
+		}
+		paths = paths[len(batch):]
+	}
+
+	// The deepest directories are removed first so their parents can become empty
+	var sortedDirs []string
+	for dir := range dirs {
+		sortedDirs = append(sortedDirs, dir)
+	}
+	sort.Slice(sortedDirs, func(i, j int) bool {
+		return len(sortedDirs[i]) > len(sortedDirs[j])
+	})
+	for _, dir := range sortedDirs {
+		if !util.IsDir(dir) {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err == nil && len(entries) == 0 {
+			err := b.runInstallCmd(ctx, "rmdir", dir)
+			if err != nil {
+				return fmt.Errorf("unable to remove %s: %w", dir, err)
+			}
+		}
+	}
+	return nil
+}
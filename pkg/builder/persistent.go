@@ -0,0 +1,91 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package builder
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/persistent"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// persistentInstallChanged returns whether the persistent install of the software package is
+// registered with inputs that are different from the current ones
+func (b *Builder) persistentInstallChanged() (bool, error) {
+	if b.Persistent == "" {
+		return false, nil
+	}
+	entry, err := persistent.NewRegistry(b.Persistent).Get(b.App.Name, b.App.Version)
+	if err != nil {
+		return false, fmt.Errorf("unable to get %s from the registry: %w", b.App.Name, err)
+	}
+	if entry == nil {
+		return false, nil
+	}
+	hashes := b.stageInputHashes()
+	return entry.InputHash != hashes[len(hashes)-1], nil
+}
+
+// registerPersistentInstall adds the software package that was just installed to the registry of the
+// persistent directory
+func (b *Builder) registerPersistentInstall(appInstallDir string, inputHash string) error {
+	entry := persistent.Entry{
+		Name:       b.App.Name,
+		Version:    b.App.Version,
+		URL:        b.App.Source.URL,
+		Branch:     b.App.Source.Branch,
+		Commit:     b.App.Source.Commit,
+		Digest:     b.App.Source.Digest,
+		InstallDir: appInstallDir,
+		BuildTime:  time.Now(),
+		InputHash:  inputHash,
+	}
+	err := persistent.NewRegistry(b.Persistent).Register(entry)
+	if err != nil {
+		return fmt.Errorf("unable to register %s: %w", b.App.Name, err)
+	}
+	return nil
+}
+
+// uninstallPersistent uninstalls the software package from the persistent directory. Only software
+// packages from the registry are uninstalled, anything else in the persistent directory is left in place.
+func (b *Builder) uninstallPersistent(ctx context.Context) error {
+	entry, err := persistent.NewRegistry(b.Persistent).Get(b.App.Name, b.App.Version)
+	if err != nil {
+		return fmt.Errorf("unable to get %s from the registry: %w", b.App.Name, err)
+	}
+	if entry == nil {
+		process.Logf(ctx, "%s is not registered in %s, not uninstalling software from host", b.App.Name, b.Persistent)
+		return nil
+	}
+
+	if util.PathExists(entry.InstallDir) {
+		process.Logf(ctx, "-> Uninstalling %s from %s", b.App.Name, entry.InstallDir)
+		b.Env.InstallDir = b.Persistent
+		manifest, err := b.LoadFilesManifest()
+		if err == nil {
+			err = b.uninstallFiles(ctx, manifest)
+		} else {
+			err = b.runInstallCmd(ctx, "rm", "-rf", entry.InstallDir)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to uninstall %s: %w", b.App.Name, err)
+		}
+	}
+	return b.unregisterPersistentInstall()
+}
+
+// unregisterPersistentInstall removes the software package from the registry of the persistent directory
+func (b *Builder) unregisterPersistentInstall() error {
+	err := persistent.NewRegistry(b.Persistent).Unregister(b.App.Name, b.App.Version)
+	if err != nil {
+		return fmt.Errorf("unable to unregister %s: %w", b.App.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+package builder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// Stage is the name of one of the steps required to install a software package
+type Stage string
+
+const (
+	// StageFetch is the stage getting the source code of the software package
+	StageFetch Stage = "fetch"
+
+	// StageUnpack is the stage unpacking the source code of the software package
+	StageUnpack Stage = "unpack"
+
+	// StageConfigure is the stage configuring the software package
+	StageConfigure Stage = "configure"
+
+	// StageBuild is the stage compiling the software package
+	StageBuild Stage = "build"
+
+	// StageTest is the stage running the test suite of the software package
+	StageTest Stage = "test"
+
+	// StageInstall is the stage installing the software package
+	StageInstall Stage = "install"
+
+	// stateFileSuffix is the suffix of the file, next to the application's build directory, where
+	// the state of the build is saved
+	stateFileSuffix = ".state"
+)
+
+// Stages is the ordered list of all the stages required to install a software package
+var Stages = []Stage{StageFetch, StageUnpack, StageConfigure, StageBuild, StageTest, StageInstall}
+
+// stageState is the state of a stage that successfully completed
+type stageState struct {
+	// Stage is the name of the stage
+	Stage Stage `json:"stage"`
+
+	// InputHash is the hash of all the inputs of the stage, including the inputs of all the previous stages
+	InputHash string `json:"input_hash"`
+
+	// Completed is the time when the stage completed
+	Completed time.Time `json:"completed"`
+}
+
+// buildState is the state of the build of a software package, saved on disk so an interrupted or failed
+// build can be resumed
+type buildState struct {
+	// Stages is the list of stages that completed, in order
+	Stages []stageState `json:"stages"`
+
+	// SrcPath is the path to the source code after the fetch stage
+	SrcPath string `json:"src_path"`
+
+	// SrcDir is the directory where the source code is after the unpack stage
+	SrcDir string `json:"src_dir"`
+}
+
+func stageIndex(stage Stage) int {
+	for idx, s := range Stages {
+		if s == stage {
+			return idx
+		}
+	}
+	return -1
+}
+
+func hashInputs(prevHash string, inputs ...string) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	for _, input := range inputs {
+		h.Write([]byte{0})
+		h.Write([]byte(input))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashFile(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// stageInputHashes computes the input hash of all the stages. The hash of a stage includes the hash of
+// the previous stage so any change invalidates all the stages that follow.
+func (b *Builder) stageInputHashes() []string {
+	var hashes []string
+	prevHash := ""
+	for _, stage := range Stages {
+		var inputs []string
+		switch stage {
+		case StageFetch:
+			inputs = []string{b.App.Name, b.App.Version, b.App.Source.URL, b.App.Source.Branch, b.App.Source.Commit, b.App.Source.Digest, b.App.Source.BranchCheckoutPrelude, b.App.Tarball}
+		case StageUnpack:
+			inputs = []string{b.Env.BuildDir}
+		case StageConfigure:
+			inputs = []string{b.Env.InstallDir, b.App.AutotoolsCfg.ConfigurePreludeCmd}
+			inputs = append(inputs, b.App.AutotoolsCfg.ExtraConfigureArgs...)
+			inputs = append(inputs, string(b.Env.EnvMode))
+			inputs = append(inputs, b.Env.EnvPassthrough...)
+			if b.Env.EnvMode == buildenv.EnvHermetic {
+				// The inherited environment changes all the time so only a hermetic environment can be
+				// fully part of the inputs
+				inputs = append(inputs, b.Env.GetEffectiveEnv()...)
+			} else {
+				inputs = append(inputs, b.Env.Env...)
+			}
+		case StageBuild:
+			inputs = []string{b.BuildScript, hashFile(b.BuildScript)}
+			inputs = append(inputs, b.Env.MakeExtraArgs...)
+		case StageTest:
+			inputs = []string{fmt.Sprintf("%t", b.RunTests)}
+		case StageInstall:
+			priv := b.getPrivilege()
+			inputs = []string{string(priv.Mode), priv.User, strings.Join(priv.Wrapper, " "), b.App.InstallCmd}
+		}
+		prevHash = hashInputs(prevHash, inputs...)
+		hashes = append(hashes, prevHash)
+	}
+	return hashes
+}
+
+func (b *Builder) stateFilePath() string {
+	return b.Env.GetAppBuildDir(&b.App) + stateFileSuffix
+}
+
+func (b *Builder) loadState() (*buildState, error) {
+	state := new(buildState)
+	path := b.stateFilePath()
+	if !util.FileExists(path) {
+		return state, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	err = json.Unmarshal(content, state)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return state, nil
+}
+
+func (b *Builder) saveState(state *buildState) error {
+	path := b.stateFilePath()
+	content, err := json.MarshalIndent(state, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the build state: %w", err)
+	}
+	// The state is written to a temporary file first so an interruption never leaves a corrupted state
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("unable to move %s to %s: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// firstIncompleteStage returns the index of the first stage that needs to be executed, based on the
+// saved state and the current input hashes
+func (state *buildState) firstIncompleteStage(hashes []string) int {
+	for idx := range Stages {
+		if idx >= len(state.Stages) || state.Stages[idx].Stage != Stages[idx] || state.Stages[idx].InputHash != hashes[idx] {
+			return idx
+		}
+	}
+	return len(Stages)
+}
+
+// truncate drops the state of the stage at index idx and of all the stages after it
+func (state *buildState) truncate(idx int) {
+	if idx < len(state.Stages) {
+		state.Stages = state.Stages[:idx]
+	}
+}
+
+// RestartFrom forces the next installation of the software package to restart from a given stage,
+// even if that stage previously completed with the same inputs
+func (b *Builder) RestartFrom(stage Stage) error {
+	idx := stageIndex(stage)
+	if idx == -1 {
+		var names []string
+		for _, s := range Stages {
+			names = append(names, string(s))
+		}
+		return fmt.Errorf("unknown stage %s, valid stages are: %s", stage, strings.Join(names, ", "))
+	}
+
+	state, err := b.loadState()
+	if err != nil {
+		return err
+	}
+	if idx >= len(state.Stages) {
+		return nil
+	}
+	state.truncate(idx)
+	return b.saveState(state)
+}
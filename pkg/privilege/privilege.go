@@ -0,0 +1,122 @@
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+// This software is licensed under a 3-clause BSD license. Please consult the
+// LICENSE.md file distributed with the sources of this project regarding your
+// rights to use or distribute this software.
+
+// Package privilege provides the capabilities to execute commands with elevated privileges, for
+// instance to install software in a directory that is not writable by the current user.
+package privilege
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/BTMichalowicz/go_exec/pkg/advexec"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+)
+
+// Mode is the mechanism used to elevate privileges
+type Mode string
+
+const (
+	// None means that commands are executed as the current user
+	None Mode = ""
+
+	// Sudo means that commands are executed with 'sudo -n', i.e., as root without password
+	Sudo Mode = "sudo"
+
+	// SudoUser means that commands are executed with 'sudo -n -u <user>', i.e., as a service account
+	SudoUser Mode = "sudo-user"
+
+	// Wrapper means that commands are prefixed with a custom command, e.g., 'doas'
+	Wrapper Mode = "wrapper"
+)
+
+// Strategy specifies how to execute commands that require elevated privileges.
+// Note that there is no support for interactive password management, the privileges must be
+// elevated without any user interaction.
+type Strategy struct {
+	// Mode is the mechanism used to elevate privileges
+	Mode Mode
+
+	// User is the account used to execute commands when the mode is SudoUser
+	User string
+
+	// Wrapper is the command, with its arguments, used to prefix commands when the mode is Wrapper
+	Wrapper []string
+}
+
+// Required returns whether the strategy actually elevates privileges
+func (s *Strategy) Required() bool {
+	return s.Mode != None
+}
+
+func (s *Strategy) prefix() ([]string, error) {
+	switch s.Mode {
+	case None:
+		return nil, nil
+	case Sudo:
+		return []string{"sudo", "-n"}, nil
+	case SudoUser:
+		if s.User == "" {
+			return nil, fmt.Errorf("the user to execute commands as is undefined")
+		}
+		return []string{"sudo", "-n", "-u", s.User}, nil
+	case Wrapper:
+		if len(s.Wrapper) == 0 {
+			return nil, fmt.Errorf("the privilege wrapper command is undefined")
+		}
+		return s.Wrapper, nil
+	}
+	return nil, fmt.Errorf("unsupported privilege mode: %s", s.Mode)
+}
+
+// Wrap returns the binary and the arguments to use to execute a command with the strategy
+func (s *Strategy) Wrap(bin string, args []string) (string, []string, error) {
+	prefix, err := s.prefix()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(prefix) == 0 {
+		return bin, args, nil
+	}
+
+	wrapperBin, err := exec.LookPath(prefix[0])
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to find %s: %w", prefix[0], err)
+	}
+	wrappedArgs := append([]string{}, prefix[1:]...)
+	wrappedArgs = append(wrappedArgs, bin)
+	wrappedArgs = append(wrappedArgs, args...)
+	return wrapperBin, wrappedArgs, nil
+}
+
+// Run executes a command with the strategy, stopping when the context is done
+func (s *Strategy) Run(ctx context.Context, execDir string, bin string, args ...string) error {
+	var cmd advexec.Advcmd
+	var err error
+	cmd.BinPath, cmd.CmdArgs, err = s.Wrap(bin, args)
+	if err != nil {
+		return err
+	}
+	cmd.ExecDir = execDir
+	res := process.Run(ctx, &cmd)
+	if res.Err != nil {
+		return fmt.Errorf("command %s %s failed: %w - stdout: %s - stderr: %s", cmd.BinPath, strings.Join(cmd.CmdArgs, " "), res.Err, res.Stdout, res.Stderr)
+	}
+	return nil
+}
+
+// Check makes sure that privileges can be elevated without any user interaction
+func (s *Strategy) Check(ctx context.Context) error {
+	if !s.Required() {
+		return nil
+	}
+	err := s.Run(ctx, "", "true")
+	if err != nil {
+		return fmt.Errorf("unable to elevate privileges without interaction (mode: %s): %w", s.Mode, err)
+	}
+	return nil
+}
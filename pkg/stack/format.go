@@ -0,0 +1,136 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileFormat is the format of a stack definition or configuration file
+type fileFormat string
+
+const (
+	formatJSON fileFormat = "json"
+	formatYAML fileFormat = "yaml"
+	formatTOML fileFormat = "toml"
+)
+
+// getFileFormat returns the format of a file based on its extension, JSON being the default
+func getFileFormat(path string) fileFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return formatJSON
+}
+
+// caseSensitive returns whether the keys of the format must exactly match the name of the fields they
+// are decoded into
+func (f fileFormat) caseSensitive() bool {
+	return f == formatYAML
+}
+
+func (f fileFormat) decode(content []byte, v interface{}) error {
+	switch f {
+	case formatYAML:
+		return yaml.Unmarshal(content, v)
+	case formatTOML:
+		return toml.Unmarshal(content, v)
+	}
+	return json.Unmarshal(content, v)
+}
+
+func (f fileFormat) encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	switch f {
+	case formatYAML:
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err := enc.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+		if err != nil {
+			return nil, err
+		}
+	case formatTOML:
+		err := toml.NewEncoder(&buf).Encode(v)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		content, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(content)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeFile decodes a file, in the format matching its extension
+func decodeFile(path string, v interface{}) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read the content of %s: %w", path, err)
+	}
+	err = getFileFormat(path).decode(content, v)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return nil
+}
+
+// convertFile converts a file into the format of another file. It fails if the file includes fields
+// that would be lost in the conversion.
+func convertFile(srcPath string, dstPath string, v interface{}, check func(path string) ([]Diagnostic, error)) error {
+	diags, err := check(srcPath)
+	if err != nil {
+		return err
+	}
+	if len(diags) > 0 {
+		return fmt.Errorf("unable to convert %s: %w", srcPath, &ValidationError{Diagnostics: diags})
+	}
+
+	err = decodeFile(srcPath, v)
+	if err != nil {
+		return err
+	}
+	content, err := getFileFormat(dstPath).encode(v)
+	if err != nil {
+		return fmt.Errorf("unable to marshal content of %s: %w", srcPath, err)
+	}
+	err = ioutil.WriteFile(dstPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", dstPath, err)
+	}
+	return nil
+}
+
+// ConvertDefFile converts a stack definition file into another format, e.g., to migrate a JSON
+// definition to YAML. The format of each file is based on its extension.
+func ConvertDefFile(srcPath string, dstPath string) error {
+	return convertFile(srcPath, dstPath, new(StackDef), checkDefinitionFile)
+}
+
+// ConvertConfigFile converts a stack configuration file into another format. The format of each
+// file is based on its extension.
+func ConvertConfigFile(srcPath string, dstPath string) error {
+	return convertFile(srcPath, dstPath, new(StackCfg), checkConfigFile)
+}
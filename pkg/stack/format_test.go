@@ -0,0 +1,88 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	jsonDefPath := filepath.Join(dir, "def.json")
+	jsonCfgPath := filepath.Join(dir, "cfg.json")
+	err = ioutil.WriteFile(jsonDefPath, []byte(`{"name": "test", "type": "public", "components": [{"name": "hwloc", "URL": "file:///hwloc", "branch": "v2.x"}, {"name": "ompi", "URL": "file:///ompi", "configure_dependency": "hwloc", "configure_params": "--enable-debug", "timeouts": {"build": "1h"}}]}`), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", jsonDefPath, err)
+	}
+	err = ioutil.WriteFile(jsonCfgPath, []byte(`{"installDir": "/tmp/test"}`), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", jsonCfgPath, err)
+	}
+	ref := &Config{DefFilePath: jsonDefPath, ConfigFilePath: jsonCfgPath}
+	err = ref.Load()
+	if err != nil {
+		t.Fatalf("unable to load %s: %s", jsonDefPath, err)
+	}
+
+	for _, ext := range []string{".yaml", ".yml", ".toml"} {
+		c := &Config{DefFilePath: filepath.Join(dir, "def"+ext), ConfigFilePath: filepath.Join(dir, "cfg"+ext)}
+		err := ConvertDefFile(jsonDefPath, c.DefFilePath)
+		if err != nil {
+			t.Fatalf("unable to convert %s: %s", jsonDefPath, err)
+		}
+		err = ConvertConfigFile(jsonCfgPath, c.ConfigFilePath)
+		if err != nil {
+			t.Fatalf("unable to convert %s: %s", jsonCfgPath, err)
+		}
+		err = c.Validate()
+		if err != nil {
+			t.Fatalf("%s: validation failed: %s", ext, err)
+		}
+		if !reflect.DeepEqual(c.StackDefinition, ref.StackDefinition) || !reflect.DeepEqual(c.StackConfig, ref.StackConfig) {
+			t.Fatalf("%s: loaded %+v %+v instead of %+v %+v", ext, c.StackDefinition, c.StackConfig, ref.StackDefinition, ref.StackConfig)
+		}
+	}
+
+	// The keys are the same in all the formats
+	jsonConvertedPath := filepath.Join(dir, "converted.json")
+	err = ConvertDefFile(filepath.Join(dir, "def.yaml"), jsonConvertedPath)
+	if err != nil {
+		t.Fatalf("unable to convert to JSON: %s", err)
+	}
+	content, err := ioutil.ReadFile(jsonConvertedPath)
+	if err != nil || !strings.Contains(string(content), `"components"`) {
+		t.Fatalf("the components are not saved with the components key (%v): %s", err, content)
+	}
+
+	// YAML keys are case sensitive so a key that does not exactly match is reported
+	yamlDefPath := filepath.Join(dir, "invalid.yaml")
+	err = ioutil.WriteFile(yamlDefPath, []byte("# Comments are supported\nname: test\ncomponents:\n  - name: hwloc\n    url: file:///hwloc\n"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", yamlDefPath, err)
+	}
+	c := &Config{DefFilePath: yamlDefPath, ConfigFilePath: jsonCfgPath}
+	err = c.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Diagnostics[0] != (Diagnostic{Component: "hwloc", Field: "url", Message: "unknown field"}) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = ConvertDefFile(yamlDefPath, filepath.Join(dir, "invalid.json"))
+	if err == nil {
+		t.Fatalf("conversion succeeded while a field would be lost")
+	}
+}
@@ -0,0 +1,504 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// generationsDirName is the name of the directory, in the stack base directory, where all the
+	// generations of the stack are installed
+	generationsDirName = "generations"
+
+	// currentGenerationLinkName is the name of the symbolic link, in the stack base directory, to the
+	// generation in use
+	currentGenerationLinkName = "current"
+
+	// generationCompleteFileName is the name of the file created in a generation once all its
+	// components are successfully installed
+	generationCompleteFileName = ".complete"
+
+	// generationRecordDirName is the name of the directory of a generation where the records of its
+	// components are saved
+	generationRecordDirName = ".records"
+)
+
+// Generation is one installation of the entire stack
+type Generation struct {
+	// Number identifies the generation, generations being numbered in the order they are created
+	Number int
+
+	// Path is the directory where the generation is installed
+	Path string
+
+	// Created is when the generation was created
+	Created time.Time
+
+	// Complete specifies whether all the components of the generation were successfully installed
+	Complete bool
+
+	// Current specifies whether the generation is the one in use
+	Current bool
+}
+
+func (c *Config) getGenerationsDir() string {
+	return filepath.Join(c.getStackBasedir(), generationsDirName)
+}
+
+func (c *Config) getGenerationDir(number int) string {
+	return filepath.Join(c.getGenerationsDir(), strconv.Itoa(number))
+}
+
+// getInstallRoot returns the directory where the components of the stack are installed. With
+// generations, it is the generation being installed during an installation and the symbolic link to
+// the current generation otherwise, so everything referring to the components, e.g., modulefiles,
+// follows the generation in use.
+func (c *Config) getInstallRoot() string {
+	if !c.Generations {
+		return filepath.Join(c.getStackBasedir(), "install")
+	}
+	if c.generation > 0 {
+		return c.getGenerationDir(c.generation)
+	}
+	return filepath.Join(c.getStackBasedir(), currentGenerationLinkName)
+}
+
+// currentGeneration returns the number of the generation in use, 0 when there is none
+func (c *Config) currentGeneration() (int, error) {
+	link := filepath.Join(c.getStackBasedir(), currentGenerationLinkName)
+	if _, err := os.Lstat(link); os.IsNotExist(err) {
+		return 0, nil
+	}
+	target, err := os.Readlink(link)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read link %s: %w", link, err)
+	}
+	number, err := strconv.Atoi(filepath.Base(target))
+	if err != nil {
+		return 0, fmt.Errorf("%s points to %s, which is not a generation", link, target)
+	}
+	return number, nil
+}
+
+// ListGenerations returns all the generations of the stack, from the oldest to the most recent
+func (c *Config) ListGenerations() ([]Generation, error) {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+	current, err := c.currentGeneration()
+	if err != nil {
+		return nil, err
+	}
+
+	generationsDir := c.getGenerationsDir()
+	if !util.PathExists(generationsDir) {
+		return nil, nil
+	}
+	entries, err := ioutil.ReadDir(generationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", generationsDir, err)
+	}
+	var generations []Generation
+	for _, entry := range entries {
+		number, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(generationsDir, entry.Name())
+		generations = append(generations, Generation{
+			Number:   number,
+			Path:     path,
+			Created:  entry.ModTime(),
+			Complete: util.FileExists(filepath.Join(path, generationCompleteFileName)),
+			Current:  number == current,
+		})
+	}
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].Number < generations[j].Number
+	})
+	return generations, nil
+}
+
+// prepareGeneration selects the generation where the stack is installed: the most recent generation
+// when a previous installation into it did not complete, a new generation otherwise. The components
+// whose inputs did not change since they were installed in the current generation are linked from it
+// so only the components that changed are built. The stack must be locked exclusively so no other
+// process selects the same generation.
+func (c *Config) prepareGeneration() error {
+	generations, err := c.ListGenerations()
+	if err != nil {
+		return err
+	}
+	current, err := c.currentGeneration()
+	if err != nil {
+		return err
+	}
+
+	number := 0
+	if len(generations) > 0 {
+		latest := generations[len(generations)-1]
+		if !latest.Complete && latest.Number > current {
+			number = latest.Number
+			log.Printf("Resuming the installation of generation %d", number)
+		}
+	}
+	if number == 0 {
+		number, err = c.newGeneration("")
+		if err != nil {
+			return err
+		}
+	}
+
+	if current > 0 {
+		records, err := c.generationRecords(current)
+		if err != nil {
+			return err
+		}
+		for idx := range c.StackDefinition.Components {
+			comp := &c.StackDefinition.Components[idx]
+			record := records[comp.id()]
+			if record == nil || record.Status != StatusSucceeded || record.InputHash != c.inputs[comp.id()] {
+				continue
+			}
+			reused, err := c.reuseComponent(comp, record, current, number)
+			if err != nil {
+				return err
+			}
+			if reused {
+				log.Printf("-> %s did not change, reusing it from generation %d", comp.id(), current)
+			}
+		}
+	}
+	c.generation = number
+	return nil
+}
+
+// generationRecords returns the records of the components of a generation
+func (c *Config) generationRecords(number int) (map[string]*componentRecord, error) {
+	generation := c.generation
+	c.generation = number
+	defer func() {
+		c.generation = generation
+	}()
+	records := make(map[string]*componentRecord)
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		record, err := c.loadRecord(comp.id())
+		if err != nil {
+			log.Printf("%s", err)
+			continue
+		}
+		records[comp.id()] = record
+	}
+	return records, nil
+}
+
+// reuseComponent links a component installed in a generation into another generation, with its
+// record, and returns whether the component was linked. Nothing is done when the component is not
+// installed in the source generation or already installed in the target generation.
+func (c *Config) reuseComponent(comp *Component, record *componentRecord, from int, to int) (bool, error) {
+	generation := c.generation
+	defer func() {
+		c.generation = generation
+	}()
+	c.generation = from
+	previousDir := c.getComponentInstallDir(comp)
+	c.generation = to
+	installDir := c.getComponentInstallDir(comp)
+	if !util.PathExists(previousDir) || util.PathExists(installDir) {
+		return false, nil
+	}
+
+	// Links always point to where the component was actually built since the installed files refer to
+	// that directory
+	target := previousDir
+	if info, err := os.Lstat(previousDir); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err = os.Readlink(previousDir)
+		if err != nil {
+			return false, fmt.Errorf("unable to read link %s: %w", previousDir, err)
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(installDir), defaultPermission)
+	if err != nil {
+		return false, fmt.Errorf("unable to create %s: %w", filepath.Dir(installDir), err)
+	}
+	err = os.Symlink(target, installDir)
+	if err != nil {
+		return false, fmt.Errorf("unable to create link to %s: %w", target, err)
+	}
+	if record != nil {
+		err = c.saveRecord(comp.id(), record)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// newGeneration creates a generation after all the existing ones and returns its number. When dir is
+// not empty, the directory becomes the new generation.
+func (c *Config) newGeneration(dir string) (int, error) {
+	generations, err := c.ListGenerations()
+	if err != nil {
+		return 0, err
+	}
+	number := 1
+	if len(generations) > 0 {
+		number = generations[len(generations)-1].Number + 1
+	}
+	generationDir := c.getGenerationDir(number)
+	err = os.MkdirAll(c.getGenerationsDir(), defaultPermission)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create %s: %w", c.getGenerationsDir(), err)
+	}
+	if dir == "" {
+		err = os.Mkdir(generationDir, defaultPermission)
+		if err != nil {
+			return 0, fmt.Errorf("unable to create %s: %w", generationDir, err)
+		}
+		return number, nil
+	}
+	err = os.Rename(dir, generationDir)
+	if err != nil {
+		return 0, fmt.Errorf("unable to move %s to %s: %w", dir, generationDir, err)
+	}
+	return number, nil
+}
+
+// removeFromGeneration creates a new generation with all the components of the current generation
+// except the removed ones, so the current generation is never modified, and returns its number
+func (c *Config) removeFromGeneration(removed []string) (int, error) {
+	current, err := c.currentGeneration()
+	if err != nil || current == 0 {
+		return 0, err
+	}
+	records, err := c.generationRecords(current)
+	if err != nil {
+		return 0, err
+	}
+	number, err := c.newGeneration("")
+	if err != nil {
+		return 0, err
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range removed {
+		skip[name] = true
+	}
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		if skip[comp.id()] {
+			continue
+		}
+		_, err := c.reuseComponent(comp, records[comp.id()], current, number)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return number, nil
+}
+
+// switchGeneration atomically makes a generation the one in use
+func (c *Config) switchGeneration(number int) error {
+	link := filepath.Join(c.getStackBasedir(), currentGenerationLinkName)
+	tmpLink := link + ".tmp"
+	err := os.RemoveAll(tmpLink)
+	if err != nil {
+		return fmt.Errorf("unable to remove %s: %w", tmpLink, err)
+	}
+	err = os.Symlink(filepath.Join(generationsDirName, strconv.Itoa(number)), tmpLink)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", tmpLink, err)
+	}
+	err = os.Rename(tmpLink, link)
+	if err != nil {
+		os.Remove(tmpLink)
+		return fmt.Errorf("unable to move %s to %s: %w", tmpLink, link, err)
+	}
+	return nil
+}
+
+// activateGeneration marks the generation being installed as complete and makes it the one in use
+func (c *Config) activateGeneration() error {
+	path := filepath.Join(c.getGenerationDir(c.generation), generationCompleteFileName)
+	err := ioutil.WriteFile(path, []byte(time.Now().Format(time.RFC3339)+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	err = c.switchGeneration(c.generation)
+	if err != nil {
+		return err
+	}
+	log.Printf("Generation %d is now the current generation of the stack", c.generation)
+	return nil
+}
+
+// Rollback makes an earlier complete generation the one in use again
+func (c *Config) Rollback(number int) error {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+	stackLock, err := c.lockStack(context.Background(), false)
+	if err != nil {
+		return err
+	}
+	defer stackLock.Release()
+
+	generations, err := c.ListGenerations()
+	if err != nil {
+		return err
+	}
+	for _, generation := range generations {
+		if generation.Number != number {
+			continue
+		}
+		if !generation.Complete {
+			return fmt.Errorf("generation %d is incomplete", number)
+		}
+		err := c.switchGeneration(number)
+		if err != nil {
+			return err
+		}
+		log.Printf("Generation %d is now the current generation of the stack", number)
+		return nil
+	}
+	return fmt.Errorf("generation %d does not exist", number)
+}
+
+// componentLinks returns the target of all the components of an install directory that are links,
+// e.g., to the generation or to the store where they were built
+func componentLinks(installDir string) ([]string, error) {
+	var targets []string
+	var scan func(dir string, depth int) error
+	scan = func(dir string, depth int) error {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			if entry.Mode()&os.ModeSymlink != 0 {
+				target, err := os.Readlink(path)
+				if err != nil {
+					return fmt.Errorf("unable to read link %s: %w", path, err)
+				}
+				targets = append(targets, target)
+				continue
+			}
+			// Components with a version are installed in a directory per version
+			if entry.IsDir() && depth == 0 {
+				err := scan(path, depth+1)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return targets, scan(installDir, 0)
+}
+
+// referencedGenerations returns the generations that components of a generation are linked from
+func (c *Config) referencedGenerations(generationDir string) (map[int]bool, error) {
+	targets, err := componentLinks(generationDir)
+	if err != nil {
+		return nil, err
+	}
+	generationsDir := c.getGenerationsDir() + string(filepath.Separator)
+	referenced := make(map[int]bool)
+	for _, target := range targets {
+		if !strings.HasPrefix(target, generationsDir) {
+			continue
+		}
+		elts := strings.SplitN(strings.TrimPrefix(target, generationsDir), string(filepath.Separator), 2)
+		if number, err := strconv.Atoi(elts[0]); err == nil {
+			referenced[number] = true
+		}
+	}
+	return referenced, nil
+}
+
+// CollectGenerations removes the old generations of the stack, keeping the current generation and the
+// keep most recent other complete generations. Generations that kept generations reuse components
+// from are also kept. The number of the removed generations is returned.
+func (c *Config) CollectGenerations(keep int) ([]int, error) {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+	stackLock, err := c.lockStack(context.Background(), false)
+	if err != nil {
+		return nil, err
+	}
+	defer stackLock.Release()
+
+	generations, err := c.ListGenerations()
+	if err != nil {
+		return nil, err
+	}
+	kept := make(map[int]bool)
+	for idx := len(generations) - 1; idx >= 0; idx-- {
+		generation := generations[idx]
+		if generation.Current {
+			kept[generation.Number] = true
+		} else if generation.Complete && keep > 0 {
+			kept[generation.Number] = true
+			keep--
+		}
+	}
+	// Generations reused by kept generations are kept as well. Components are always linked to the
+	// generation where they were built, so a single pass is enough.
+	for _, generation := range generations {
+		if !kept[generation.Number] {
+			continue
+		}
+		referenced, err := c.referencedGenerations(generation.Path)
+		if err != nil {
+			return nil, err
+		}
+		for number := range referenced {
+			kept[number] = true
+		}
+	}
+
+	var removed []int
+	for _, generation := range generations {
+		if kept[generation.Number] {
+			continue
+		}
+		log.Printf("Removing generation %d...", generation.Number)
+		err := os.RemoveAll(generation.Path)
+		if err != nil {
+			return removed, fmt.Errorf("unable to remove %s: %w", generation.Path, err)
+		}
+		removed = append(removed, generation.Number)
+	}
+	return removed, nil
+}
@@ -0,0 +1,181 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/lock"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestGenerations(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	def := &StackDef{
+		Name: "test",
+		Type: "public",
+		Components: []Component{
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "")},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+	c.Generations = true
+
+	checkCurrent := func(expected int) {
+		current, err := c.currentGeneration()
+		if err != nil || current != expected {
+			t.Fatalf("current generation is %d instead of %d (%v)", current, expected, err)
+		}
+	}
+
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	checkCurrent(1)
+	if !util.FileExists(filepath.Join(c.getStackBasedir(), "current", "hwloc", "bin", "helloworld")) {
+		t.Fatalf("hwloc is not available through the current generation")
+	}
+
+	// Only ucx changes so the second generation reuses hwloc from the first one
+	def.Components[1].ConfigureParams = "--enable-debug"
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	checkCurrent(2)
+	target, err := os.Readlink(filepath.Join(c.getGenerationDir(2), "hwloc"))
+	if err != nil || target != filepath.Join(c.getGenerationDir(1), "hwloc") {
+		t.Fatalf("hwloc is not reused from generation 1: %s (%v)", target, err)
+	}
+	if !util.IsDir(filepath.Join(c.getGenerationDir(2), "ucx")) {
+		t.Fatalf("ucx was not installed in generation 2")
+	}
+
+	// A failed installation leaves the current generation untouched
+	def.Components[1].URL = createLocalComponent(t, filepath.Join(srcDir, "broken"), "ucx", "exit 1")
+	err = c.InstallStackContext(context.Background())
+	if err == nil {
+		t.Fatalf("installation of a failing component succeeded")
+	}
+	checkCurrent(2)
+	generations, err := c.ListGenerations()
+	if err != nil || len(generations) != 3 || generations[2].Complete || !generations[1].Current {
+		t.Fatalf("invalid list of generations (%v): %+v", err, generations)
+	}
+	err = c.Rollback(3)
+	if err == nil {
+		t.Fatalf("rollback to an incomplete generation succeeded")
+	}
+
+	err = c.Rollback(1)
+	if err != nil {
+		t.Fatalf("unable to roll back to generation 1: %s", err)
+	}
+	checkCurrent(1)
+	err = c.GenerateModules("", "")
+	if err != nil {
+		t.Fatalf("unable to generate the modulefiles: %s", err)
+	}
+	modulefile, err := ioutil.ReadFile(filepath.Join(c.getStackBasedir(), "modulefiles", "ucx"))
+	if err != nil || !strings.Contains(string(modulefile), filepath.Join(c.getStackBasedir(), "current", "ucx")) {
+		t.Fatalf("the modulefile of ucx does not point to the current generation (%v): %s", err, modulefile)
+	}
+
+	// Generation 1 is kept since generation 2 reuses hwloc from it
+	err = c.Rollback(2)
+	if err != nil {
+		t.Fatalf("unable to roll back to generation 2: %s", err)
+	}
+	removed, err := c.CollectGenerations(0)
+	if err != nil {
+		t.Fatalf("unable to collect the generations: %s", err)
+	}
+	if len(removed) != 1 || removed[0] != 3 {
+		t.Fatalf("removed generations %v instead of generation 3", removed)
+	}
+	if !util.FileExists(filepath.Join(c.getStackBasedir(), "current", "hwloc", "bin", "helloworld")) {
+		t.Fatalf("hwloc is no longer available after collecting the generations")
+	}
+
+	// Installations into generations always lock the entire stack
+	c.ComponentLocks = true
+	other, err := c.lockStack(context.Background(), true)
+	if err != nil {
+		t.Fatalf("unable to lock the stack: %s", err)
+	}
+	err = c.InstallStackContext(context.Background())
+	var lockedErr *lock.LockedError
+	if !errors.As(err, &lockedErr) {
+		t.Fatalf("installing while the stack is used did not fail: %v", err)
+	}
+	other.Release()
+
+	// The exported stack is the current generation, with the reused components
+	if _, err := exec.LookPath("bzip2"); err == nil {
+		err = c.Export()
+		if err != nil {
+			t.Fatalf("unable to export the stack: %s", err)
+		}
+		imported, cleanupImported := newTestConfig(t, def)
+		defer cleanupImported()
+		imported.Generations = true
+		err = imported.Import(filepath.Join(c.getStackBasedir(), def.Name+".tar.bz2"))
+		if err != nil {
+			t.Fatalf("unable to import the stack: %s", err)
+		}
+		info, err := os.Lstat(filepath.Join(imported.getStackBasedir(), "current", "hwloc", "bin", "helloworld"))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Fatalf("hwloc was not imported in the current generation (%v)", err)
+		}
+	}
+
+	// Removing a component creates a new generation without it
+	err = c.Remove("ucx")
+	if err != nil {
+		t.Fatalf("unable to remove ucx: %s", err)
+	}
+	checkCurrent(3)
+	if util.PathExists(filepath.Join(c.getStackBasedir(), "current", "ucx")) || !util.IsDir(filepath.Join(c.getGenerationDir(2), "ucx")) {
+		t.Fatalf("ucx was not removed from a new generation")
+	}
+	if !util.FileExists(filepath.Join(c.getStackBasedir(), "current", "hwloc", "bin", "helloworld")) {
+		t.Fatalf("hwloc is no longer available after removing ucx")
+	}
+
+	// Rolling back restores the removed component, whose modulefile and build were kept
+	for _, p := range []string{filepath.Join(c.getStackBasedir(), "modulefiles", "ucx"), filepath.Join(c.getStackBasedir(), "build", "ucx")} {
+		if !util.PathExists(p) {
+			t.Fatalf("%s was removed with ucx", p)
+		}
+	}
+	err = c.Rollback(2)
+	if err != nil {
+		t.Fatalf("unable to roll back to generation 2: %s", err)
+	}
+	if !util.FileExists(filepath.Join(c.getStackBasedir(), "current", "ucx", "bin", "helloworld")) {
+		t.Fatalf("ucx is not available after rolling back")
+	}
+	err = c.GenerateModules("", "")
+	if err != nil {
+		t.Fatalf("unable to generate the modulefiles after rolling back: %s", err)
+	}
+}
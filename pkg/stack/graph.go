@@ -0,0 +1,174 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Graph is the dependency graph of the components of a stack. Components are designated by their
+// identifier, i.e., their name, or name@version for components with a version.
+type Graph struct {
+	// Nodes is the identifier of all the components, in the order of the stack definition
+	Nodes []string
+
+	// Dependencies is the list of components each component directly depends on
+	Dependencies map[string][]string
+}
+
+// Graph builds the dependency graph of the stack based on the dependencies declared by the components
+func (c *Config) Graph() (*Graph, error) {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+
+	g := new(Graph)
+	g.Dependencies = make(map[string][]string)
+	for _, comp := range c.StackDefinition.Components {
+		if _, ok := g.Dependencies[comp.id()]; ok {
+			return nil, fmt.Errorf("component %s is defined more than once", comp.id())
+		}
+		g.Nodes = append(g.Nodes, comp.id())
+		g.Dependencies[comp.id()] = nil
+	}
+
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		for _, ref := range comp.getDependencies() {
+			dep, err := c.resolveComponent(ref)
+			if errors.Is(err, errUnknownComponent) {
+				return nil, fmt.Errorf("component %s depends on %s, which is not a component of the stack", comp.id(), ref)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("component %s depends on %s: %w", comp.id(), ref, err)
+			}
+			if dep == comp {
+				return nil, fmt.Errorf("component %s depends on itself", comp.id())
+			}
+			g.Dependencies[comp.id()] = append(g.Dependencies[comp.id()], dep.id())
+		}
+	}
+
+	return g, nil
+}
+
+// Dependents returns the name of the components that directly depend on a given component
+func (g *Graph) Dependents(name string) []string {
+	var dependents []string
+	for _, node := range g.Nodes {
+		for _, dep := range g.Dependencies[node] {
+			if dep == name {
+				dependents = append(dependents, node)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// findCycle returns the list of nodes forming a cycle in the graph, the first and last nodes being
+// the same, or nil when the graph does not have any cycle
+func (g *Graph) findCycle() []string {
+	const (
+		unvisited = iota
+		inProgress
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		state[name] = inProgress
+		path = append(path, name)
+		for _, dep := range g.Dependencies[name] {
+			switch state[dep] {
+			case inProgress:
+				for idx, n := range path {
+					if n == dep {
+						cycle := append([]string{}, path[idx:]...)
+						return append(cycle, dep)
+					}
+				}
+			case unvisited:
+				cycle := visit(dep)
+				if cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range g.Nodes {
+		if state[name] == unvisited {
+			cycle := visit(name)
+			if cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// TopologicalOrder returns the name of all the components in an order where every component comes
+// after all its dependencies. Components that do not depend on each other stay in the order of the
+// stack definition.
+func (g *Graph) TopologicalOrder() ([]string, error) {
+	cycle := g.findCycle()
+	if cycle != nil {
+		return nil, fmt.Errorf("dependency cycle detected: %s", strings.Join(cycle, " -> "))
+	}
+
+	var order []string
+	added := make(map[string]bool)
+	for len(order) < len(g.Nodes) {
+		for _, name := range g.Nodes {
+			if added[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range g.Dependencies[name] {
+				if !added[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				order = append(order, name)
+				added[name] = true
+				// Restart from the beginning to preserve the order of the stack definition as much as possible
+				break
+			}
+		}
+	}
+	return order, nil
+}
+
+// DOT returns the representation of the graph in the DOT language, where an edge goes from a component
+// to each of its dependencies
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph stack {\n")
+	for _, name := range g.Nodes {
+		sb.WriteString(fmt.Sprintf("\t%q;\n", name))
+	}
+	for _, name := range g.Nodes {
+		for _, dep := range g.Dependencies[name] {
+			sb.WriteString(fmt.Sprintf("\t%q -> %q;\n", name, dep))
+		}
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
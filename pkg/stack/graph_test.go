@@ -0,0 +1,82 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	tests := []struct {
+		name          string
+		components    []Component
+		expectedOrder []string
+		expectedError string
+	}{
+		{
+			name: "ordered",
+			components: []Component{
+				{Name: "hwloc"},
+				{Name: "pmix", ConfigureDependency: "hwloc"},
+			},
+			expectedOrder: []string{"hwloc", "pmix"},
+		},
+		{
+			name: "dependency listed after",
+			components: []Component{
+				{Name: "ompi", ConfigureDependency: "pmix,hwloc"},
+				{Name: "ucx"},
+				{Name: "pmix", ConfigureDependency: "hwloc"},
+				{Name: "hwloc"},
+			},
+			expectedOrder: []string{"ucx", "hwloc", "pmix", "ompi"},
+		},
+		{
+			name: "unknown dependency",
+			components: []Component{
+				{Name: "pmix", ConfigureDependency: "hwlock"},
+			},
+			expectedError: "component pmix depends on hwlock, which is not a component of the stack",
+		},
+		{
+			name: "cycle",
+			components: []Component{
+				{Name: "a", ConfigureDependency: "c"},
+				{Name: "b", ConfigureDependency: "a"},
+				{Name: "c", ConfigureDependency: "b"},
+			},
+			expectedError: "dependency cycle detected: a -> c -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		c, cleanupFn := newTestConfig(t, &StackDef{Name: "test", Components: tt.components})
+		g, err := c.Graph()
+		var order []string
+		if err == nil {
+			order, err = g.TopologicalOrder()
+		}
+		cleanupFn()
+
+		if tt.expectedError != "" {
+			if err == nil || err.Error() != tt.expectedError {
+				t.Fatalf("%s: error is %v instead of %s", tt.name, err, tt.expectedError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unable to get the install order: %s", tt.name, err)
+		}
+		if strings.Join(order, ",") != strings.Join(tt.expectedOrder, ",") {
+			t.Fatalf("%s: order is %s instead of %s", tt.name, order, tt.expectedOrder)
+		}
+		if !strings.Contains(g.DOT(), "\"pmix\" -> \"hwloc\";") {
+			t.Fatalf("%s: invalid DOT output: %s", tt.name, g.DOT())
+		}
+	}
+}
@@ -0,0 +1,124 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestConfig(t *testing.T, def *StackDef) (*Config, func()) {
+	installDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	c := new(Config)
+	c.StackConfig = &StackCfg{InstallDir: installDir}
+	c.StackDefinition = def
+	c.loaded = true
+	cleanupFn := func() {
+		os.RemoveAll(installDir)
+	}
+	return c, cleanupFn
+}
+
+const (
+	localConfigureScript = `#!/bin/sh
+prefix=/usr/local
+while [ $# -gt 0 ]; do
+	case "$1" in
+		--prefix) prefix="$2"; shift ;;
+	esac
+	shift
+done
+sed -e "s#@PREFIX@#$prefix#" Makefile.in > Makefile
+`
+
+	localMakefile = `PREFIX=@PREFIX@
+
+all:
+	@BUILD_HOOK@
+	printf '#!/bin/sh\necho hello\n' > helloworld
+	chmod +x helloworld
+
+install:
+	mkdir -p $(DESTDIR)$(PREFIX)/bin
+	cp helloworld $(DESTDIR)$(PREFIX)/bin/helloworld
+`
+)
+
+// createLocalComponent creates a minimal autotools-like software package in dir that can be built
+// without network access and returns its URL. When buildHook is not empty, it is executed at the
+// beginning of the build.
+func createLocalComponent(t *testing.T, dir string, name string, buildHook string) string {
+	srcDir := filepath.Join(dir, name)
+	err := os.MkdirAll(srcDir, 0755)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", srcDir, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(srcDir, "configure"), []byte(localConfigureScript), 0755)
+	if err != nil {
+		t.Fatalf("unable to create configure script: %s", err)
+	}
+	makefile := strings.Replace(localMakefile, "@BUILD_HOOK@", buildHook, 1)
+	err = ioutil.WriteFile(filepath.Join(srcDir, "Makefile.in"), []byte(makefile), 0644)
+	if err != nil {
+		t.Fatalf("unable to create Makefile.in: %s", err)
+	}
+	return "file://" + srcDir
+}
+
+// waitForFileHook returns a build hook that waits for a file to exist and fails if it does not
+// appear within 10 seconds
+func waitForFileHook(path string) string {
+	return fmt.Sprintf("i=0; while [ ! -f %s ] && [ $$i -lt 100 ]; do sleep 0.1; i=$$((i+1)); done; test -f %s", path, path)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s failed: %s - %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// createLocalGitComponent creates a bare Git repository, in dir, with a software package that can be
+// built without network access. It returns the path to the repository, the path to a clone where
+// commits can be made and the name of the branch.
+func createLocalGitComponent(t *testing.T, dir string) (string, string, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	workDir := filepath.Join(dir, "work")
+	createLocalComponent(t, dir, "work", "")
+	runGit(t, workDir, "init", "-q")
+	runGit(t, workDir, "add", ".")
+	runGit(t, workDir, "commit", "-q", "-m", "v1")
+	branch := runGit(t, workDir, "rev-parse", "--abbrev-ref", "HEAD")
+	repoPath := filepath.Join(dir, "hello.git")
+	runGit(t, dir, "clone", "-q", "--bare", workDir, repoPath)
+	return repoPath, workDir, branch
+}
+
+// pushLocalGitCommit adds a VERSION file to a repository created with createLocalGitComponent
+func pushLocalGitCommit(t *testing.T, workDir string, repoPath string, branch string) {
+	err := ioutil.WriteFile(filepath.Join(workDir, "VERSION"), []byte("2"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create VERSION: %s", err)
+	}
+	runGit(t, workDir, "add", ".")
+	runGit(t, workDir, "commit", "-q", "-m", "v2")
+	runGit(t, workDir, "push", "-q", repoPath, branch)
+}
@@ -0,0 +1,215 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// getDefinitionPath returns the path to a definition referenced from another definition
+func getDefinitionPath(path string, from string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(filepath.Dir(from), path)
+}
+
+// merge overrides the fields of the component with the fields set in another component. A field is
+// set when it is not the zero value of its type, so merging never resets a boolean to false or empties
+// a list.
+func (comp *Component) merge(override *Component) {
+	dst := reflect.ValueOf(comp).Elem()
+	src := reflect.ValueOf(override).Elem()
+	for i := 0; i < dst.NumField(); i++ {
+		srcField := src.Field(i)
+		field := dst.Type().Field(i)
+		if srcField.IsZero() || field.PkgPath != "" || field.Name == "AppendConfigureParams" {
+			continue
+		}
+		if srcField.Kind() == reflect.Map && !dst.Field(i).IsNil() {
+			merged := reflect.MakeMap(srcField.Type())
+			for _, m := range []reflect.Value{dst.Field(i), srcField} {
+				iter := m.MapRange()
+				for iter.Next() {
+					merged.SetMapIndex(iter.Key(), iter.Value())
+				}
+			}
+			dst.Field(i).Set(merged)
+			continue
+		}
+		dst.Field(i).Set(srcField)
+	}
+	if override.BuildScript != "" {
+		comp.buildScriptDir = override.buildScriptDir
+	}
+	comp.applyAppendConfigureParams(override.AppendConfigureParams)
+}
+
+func (comp *Component) applyAppendConfigureParams(params string) {
+	if params == "" {
+		return
+	}
+	comp.ConfigureParams = strings.TrimSpace(comp.ConfigureParams + " " + params)
+}
+
+// findComponent returns the index of the component a reference designates among the first n components
+// of the definition, -1 if there is none. The reference is either the identifier of a component or its
+// name when only one version of the component is there.
+func (def *StackDef) findComponent(ref string, n int) int {
+	match := -1
+	for idx := 0; idx < n; idx++ {
+		if def.Components[idx].id() == ref {
+			return idx
+		}
+		if def.Components[idx].Name == ref {
+			if match != -1 {
+				return -1
+			}
+			match = idx
+		}
+	}
+	return match
+}
+
+// mergeComponents merges a list of components into the components of the definition. Components of
+// the list are never merged with each other so duplicates can be reported when validating the stack.
+// A component is merged with the component of the same version, or of the same name when one of them
+// does not have a version, so the version of an inherited component can be changed while a different
+// version of a component with a version is installed side by side.
+func (def *StackDef) mergeComponents(components []Component) {
+	existing := len(def.Components)
+	for _, comp := range components {
+		idx := def.findComponent(comp.id(), existing)
+		if idx == -1 {
+			idx = def.findComponent(comp.Name, existing)
+			if idx != -1 && comp.Version != "" && def.Components[idx].Version != "" {
+				idx = -1
+			}
+		}
+		if idx != -1 {
+			def.Components[idx].merge(&comp)
+		} else {
+			comp.applyAppendConfigureParams(comp.AppendConfigureParams)
+			comp.AppendConfigureParams = ""
+			def.Components = append(def.Components, comp)
+		}
+	}
+}
+
+// mergeVars merges the variables and the environment variables that can be referenced of another
+// definition into the definition, the values of the other definition taking precedence
+func (def *StackDef) mergeVars(other *StackDef) {
+	for name, value := range other.Vars {
+		if def.Vars == nil {
+			def.Vars = make(map[string]string)
+		}
+		def.Vars[name] = value
+	}
+	for _, envVar := range other.EnvVars {
+		found := false
+		for _, v := range def.EnvVars {
+			if v == envVar {
+				found = true
+				break
+			}
+		}
+		if !found {
+			def.EnvVars = append(def.EnvVars, envVar)
+		}
+	}
+}
+
+// removeComponents removes a list of components from the definition
+func (def *StackDef) removeComponents(names []string, path string) error {
+	for _, name := range names {
+		idx := def.findComponent(name, len(def.Components))
+		if idx == -1 {
+			return fmt.Errorf("%s removes %s, which is not inherited or included", path, name)
+		}
+		def.Components = append(def.Components[:idx], def.Components[idx+1:]...)
+	}
+	return nil
+}
+
+// loadDefinition loads a stack definition and resolves its parent and included definitions. It returns
+// the flattened definition and the list of all the files that were loaded. parents is the list of the
+// definitions being resolved, used to detect cycles.
+//
+// Definitions are resolved with the following rules:
+//
+//  1. the components of the parent definition, itself resolved, are the base of the stack;
+//  2. the components of each included definition, also resolved, are merged in order;
+//  3. the components listed in 'remove' are dropped;
+//  4. the components of the definition itself are merged.
+//
+// Merging a component that is not part of the stack yet adds it at the end of the stack. Merging a
+// component with the same name as an existing component overrides, field by field, the values of the
+// existing component with the values that are set; timeouts are overridden stage by stage and
+// 'append_configure_params' is added to the existing configure parameters. Variables are merged
+// in the same order, the last definition setting a variable taking precedence.
+func loadDefinition(path string, parents []string) (*StackDef, []string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to get the absolute path of %s: %w", path, err)
+	}
+	for _, p := range parents {
+		if p == absPath {
+			return nil, nil, fmt.Errorf("cycle detected while loading definitions: %s -> %s", strings.Join(parents, " -> "), absPath)
+		}
+	}
+	parents = append(parents, absPath)
+
+	def := new(StackDef)
+	err = decodeFile(path, def)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{path}
+	for idx := range def.Components {
+		// Build scripts are relative to the definition that references them, which is only known once
+		// the variables they may reference are resolved
+		if def.Components[idx].BuildScript != "" {
+			def.Components[idx].buildScriptDir = filepath.Dir(path)
+		}
+	}
+
+	resolved := new(StackDef)
+	if def.Extends != "" {
+		parent, parentFiles, err := loadDefinition(getDefinitionPath(def.Extends, path), parents)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load the parent definition of %s: %w", path, err)
+		}
+		resolved = parent
+		files = append(files, parentFiles...)
+	}
+	for _, include := range def.Include {
+		included, includedFiles, err := loadDefinition(getDefinitionPath(include, path), parents)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load the definitions included by %s: %w", path, err)
+		}
+		resolved.mergeComponents(included.Components)
+		resolved.mergeVars(included)
+		files = append(files, includedFiles...)
+	}
+	err = resolved.removeComponents(def.Remove, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved.mergeComponents(def.Components)
+	resolved.mergeVars(def)
+
+	if def.Name != "" {
+		resolved.Name = def.Name
+	}
+	if def.Type != "" {
+		resolved.Type = def.Type
+	}
+	return resolved, files, nil
+}
@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInheritance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.yaml": `name: base
+type: public
+components:
+  - name: hwloc
+    URL: file:///hwloc
+  - name: ucx
+    URL: file:///ucx
+  - name: ompi
+    URL: file:///ompi
+    branch: main
+    configure_dependency: hwloc
+    configure_params: --with-hwloc
+    timeouts:
+      build: 1h
+      test: 1h
+`,
+		filepath.Join("common", "tools.json"): `{"components": [{"name": "imb", "URL": "file:///imb", "configure_dependency": "ompi"}]}`,
+		"debug.json": `{
+	"name": "debug",
+	"extends": "base.yaml",
+	"include": ["common/tools.json"],
+	"remove": ["ucx"],
+	"components": [
+		{"name": "ompi", "branch": "v5.0.x", "append_configure_params": "--enable-debug", "timeouts": {"build": "2h"}},
+		{"name": "pmix", "URL": "file:///pmix"}
+	]
+}`,
+		"cycle1.json":                           `{"name": "cycle", "extends": "cycle2.json"}`,
+		"cycle2.json":                           `{"name": "cycle", "include": ["cycle1.json"]}`,
+		"invalid_remove.json":                   `{"name": "test", "extends": "base.yaml", "remove": ["pmix"]}`,
+		"cfg.json":                              `{"installDir": "/tmp/test"}`,
+		filepath.Join("common", "scripts.json"): `{"components": [{"name": "custom", "URL": "file:///custom", "build_script": "${scripts_dir}/build.sh"}]}`,
+		"scripts.json":                          `{"name": "scripts", "vars": {"scripts_dir": "scripts"}, "include": ["common/scripts.json"]}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", filepath.Dir(path), err)
+		}
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", path, err)
+		}
+	}
+
+	c := &Config{DefFilePath: filepath.Join(dir, "debug.json"), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+	err = c.Validate()
+	if err != nil {
+		t.Fatalf("unable to load the stack: %s", err)
+	}
+	expected := &StackDef{
+		Name: "debug",
+		Type: "public",
+		Components: []Component{
+			{Name: "hwloc", URL: "file:///hwloc"},
+			{Name: "ompi", URL: "file:///ompi", Branch: "v5.0.x", ConfigureDependency: "hwloc", ConfigureParams: "--with-hwloc --enable-debug", Timeouts: map[string]string{"build": "2h", "test": "1h"}},
+			{Name: "imb", URL: "file:///imb", ConfigureDependency: "ompi"},
+			{Name: "pmix", URL: "file:///pmix"},
+		},
+	}
+	if !reflect.DeepEqual(c.StackDefinition, expected) {
+		t.Fatalf("resolved definition is %+v instead of %+v", c.StackDefinition, expected)
+	}
+
+	// Build scripts referencing variables are relative to the definition setting them once resolved
+	c = &Config{DefFilePath: filepath.Join(dir, "scripts.json"), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+	err = c.Load()
+	if err != nil {
+		t.Fatalf("unable to load the stack: %s", err)
+	}
+	buildScript := filepath.Join(dir, "common", "scripts", "build.sh")
+	if custom := c.getComponent("custom"); custom == nil || custom.BuildScript != buildScript {
+		t.Fatalf("build script of custom is not %s: %+v", buildScript, custom)
+	}
+
+	for _, name := range []string{"cycle1.json", "invalid_remove.json"} {
+		c := &Config{DefFilePath: filepath.Join(dir, name), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+		err = c.Load()
+		if err == nil {
+			t.Fatalf("loading %s succeeded", name)
+		}
+	}
+}
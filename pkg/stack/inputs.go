@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"strings"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// gitRevision returns the commit the branch of a Git component points to in the remote repository.
+// When the repository cannot be reached, the commit of the last installation is assumed so a stack is
+// never rebuilt only because it is used offline.
+func (c *Config) gitRevision(ctx context.Context, comp *Component) (string, error) {
+	commit, err := resolveGitCommit(ctx, comp.URL, comp.Branch)
+	if err == nil {
+		return commit, nil
+	}
+	log.Printf("unable to get the commit of %s, new commits are not detected: %s", comp.URL, err)
+	record, recordErr := c.loadRecord(comp.id())
+	if recordErr != nil || record == nil || record.Commit == "" || record.URL != comp.URL || record.Branch != comp.Branch {
+		return "", err
+	}
+	return record.Commit, nil
+}
+
+// sourceRevision returns the revision of the source code of a component: the commit or digest from the
+// lock when the stack is locked, the commit the branch of a Git repository points to, the digest of a
+// local tarball, or simply where the source code comes from otherwise
+func (c *Config) sourceRevision(ctx context.Context, comp *Component) string {
+	if c.lock != nil {
+		locked := c.lock.get(comp.id())
+		if locked != nil && (locked.Commit != "" || locked.Digest != "") {
+			return locked.Commit + locked.Digest
+		}
+	}
+	if util.DetectURLType(comp.URL) == util.GitURL {
+		commit, err := c.gitRevision(ctx, comp)
+		if err == nil {
+			return commit
+		}
+	}
+	if util.DetectURLType(comp.URL) == util.FileURL {
+		path := strings.TrimPrefix(comp.URL, "file://")
+		if util.FileExists(path) && !util.IsDir(path) {
+			digest, err := buildenv.FileDigest(path)
+			if err == nil {
+				return digest
+			}
+		}
+	}
+	return comp.URL + "@" + comp.Branch
+}
+
+// inputHashes computes the input hash of every component of the stack. The hash of a component covers
+// its definition, the revision of its source code, the build environment and the hash of all its
+// dependencies so any change also changes the hash of everything downstream. The components must be
+// given in an order where every component comes after all its dependencies.
+func (c *Config) inputHashes(ctx context.Context, graph *Graph, installOrder []string) map[string]string {
+	hashes := make(map[string]string)
+	for _, name := range installOrder {
+		comp := c.getComponent(name)
+		inputs := []string{comp.definitionHash(), c.sourceRevision(ctx, comp), string(c.EnvMode)}
+		if comp.BuildScript != "" {
+			digest, err := buildenv.FileDigest(comp.BuildScript)
+			if err == nil {
+				inputs = append(inputs, digest)
+			}
+		}
+		inputs = append(inputs, c.BuildEnv...)
+		inputs = append(inputs, c.EnvPassthrough...)
+		if c.EnvMode == buildenv.EnvHermetic {
+			inputs = append(inputs, c.HermeticPath)
+		}
+		for _, dep := range graph.Dependencies[name] {
+			inputs = append(inputs, dep+"="+hashes[dep])
+		}
+
+		h := sha256.New()
+		for _, input := range inputs {
+			h.Write([]byte(input))
+			h.Write([]byte{0})
+		}
+		hashes[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return hashes
+}
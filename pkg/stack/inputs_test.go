@@ -0,0 +1,95 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestIncrementalRebuild(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+			{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "hwloc"},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "")},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+
+	install := func() map[string]*componentRecord {
+		err := c.InstallStackContext(context.Background())
+		if err != nil {
+			t.Fatalf("unable to install the stack: %s", err)
+		}
+		records := make(map[string]*componentRecord)
+		for _, comp := range def.Components {
+			records[comp.Name], err = c.loadRecord(comp.Name)
+			if err != nil || records[comp.Name] == nil || records[comp.Name].InputHash == "" {
+				t.Fatalf("invalid record for %s: %v", comp.Name, err)
+			}
+		}
+		return records
+	}
+
+	initial := install()
+	unchanged := install()
+	for _, comp := range def.Components {
+		if !unchanged[comp.Name].Time.Equal(initial[comp.Name].Time) {
+			t.Fatalf("%s was rebuilt while nothing changed", comp.Name)
+		}
+	}
+
+	// Changing hwloc rebuilds hwloc and ompi, which depends on it, but not ucx
+	def.Components[0].ConfigureParams = "--enable-debug"
+	rebuilt := install()
+	for _, name := range []string{"hwloc", "ompi"} {
+		if rebuilt[name].Time.Equal(initial[name].Time) || rebuilt[name].InputHash == initial[name].InputHash {
+			t.Fatalf("%s was not rebuilt", name)
+		}
+		bin := filepath.Join(c.getComponentInstallDir(c.getComponent(name)), "bin", "helloworld")
+		if !util.FileExists(bin) {
+			t.Fatalf("%s does not exist after rebuilding %s", bin, name)
+		}
+	}
+	if !rebuilt["ucx"].Time.Equal(initial["ucx"].Time) {
+		t.Fatalf("ucx was rebuilt while it does not depend on hwloc")
+	}
+
+	// New commits on the branch of a Git component that is not locked trigger a rebuild
+	repoPath, workDir, branch := createLocalGitComponent(t, srcDir)
+	def.Components = append(def.Components, Component{Name: "hello", URL: repoPath, Branch: branch})
+	initial = install()
+	unchanged = install()
+	if !unchanged["hello"].Time.Equal(initial["hello"].Time) {
+		t.Fatalf("hello was rebuilt while its branch did not change")
+	}
+	pushLocalGitCommit(t, workDir, repoPath, branch)
+	rebuilt = install()
+	if rebuilt["hello"].Commit == initial["hello"].Commit || rebuilt["hello"].InputHash == initial["hello"].InputHash {
+		t.Fatalf("hello was not rebuilt after a new commit")
+	}
+	if !util.FileExists(filepath.Join(rebuilt["hello"].SrcDir, "VERSION")) {
+		t.Fatalf("the new commit of hello was not built")
+	}
+	if !rebuilt["ucx"].Time.Equal(initial["ucx"].Time) {
+		t.Fatalf("ucx was rebuilt while it did not change")
+	}
+}
@@ -0,0 +1,205 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// References to variables are ${name}; $${ is used for a literal ${, e.g., for shell variables in a
+// configure prelude
+var varRefRegexp = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}`)
+
+// interpolateString replaces all the references to variables in a string
+func interpolateString(str string, lookup func(name string) (string, error)) (string, error) {
+	var lookupErr error
+	result := varRefRegexp.ReplaceAllStringFunc(str, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		if lookupErr != nil {
+			return ref
+		}
+		value, err := lookup(ref[2 : len(ref)-1])
+		if err != nil {
+			lookupErr = err
+			return ref
+		}
+		return value
+	})
+	if lookupErr != nil {
+		return "", lookupErr
+	}
+	return result, nil
+}
+
+// componentField is a field of a component where variables are replaced
+type componentField struct {
+	name  string
+	value *string
+}
+
+// interpolation gathers the data needed to resolve the variables of a stack
+type interpolation struct {
+	c *Config
+
+	// vars are the user-defined variables that have already been resolved
+	vars map[string]string
+
+	// resolving is the list of user-defined variables being resolved, used to detect cycles
+	resolving []string
+
+	// versionsResolved specifies whether the versions of the components can be referenced
+	versionsResolved bool
+}
+
+func (i *interpolation) lookupVar(name string) (string, error) {
+	if value, ok := i.vars[name]; ok {
+		return value, nil
+	}
+	value, ok := i.c.StackDefinition.Vars[name]
+	if !ok {
+		return "", fmt.Errorf("undefined variable ${%s}", name)
+	}
+	for _, v := range i.resolving {
+		if v == name {
+			return "", fmt.Errorf("variable ${%s} references itself: %s -> %s", name, strings.Join(i.resolving, " -> "), name)
+		}
+	}
+	i.resolving = append(i.resolving, name)
+	value, err := interpolateString(value, i.lookup(nil))
+	i.resolving = i.resolving[:len(i.resolving)-1]
+	if err != nil {
+		return "", err
+	}
+	i.vars[name] = value
+	return value, nil
+}
+
+func (i *interpolation) lookupEnv(name string) (string, error) {
+	allowed := false
+	for _, v := range i.c.StackDefinition.EnvVars {
+		if v == name {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "", fmt.Errorf("environment variable %s is not listed in env_vars", name)
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("undefined environment variable %s", name)
+	}
+	return value, nil
+}
+
+// lookupComponent returns a field of a component, ref being how the component is referenced
+func (i *interpolation) lookupComponent(comp *Component, ref string, field string) (string, error) {
+	switch field {
+	case "install_dir":
+		return i.c.getComponentInstallDir(comp), nil
+	case "version":
+		if !i.versionsResolved {
+			return "", fmt.Errorf("versions cannot reference the version of a component")
+		}
+		if comp.Version == "" {
+			return "", fmt.Errorf("undefined variable ${%s.version}: %s does not have a version", ref, comp.Name)
+		}
+		return comp.Version, nil
+	}
+	return "", fmt.Errorf("undefined variable ${%s.%s}", ref, field)
+}
+
+// lookup returns the function looking up variables from the fields of a component, self being nil
+// when the fields are not specific to a component
+func (i *interpolation) lookup(self *Component) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		switch name {
+		case "stack.name":
+			return i.c.StackDefinition.Name, nil
+		case "stack.install_dir":
+			return i.c.StackConfig.InstallDir, nil
+		}
+		if strings.HasPrefix(name, "env.") {
+			return i.lookupEnv(strings.TrimPrefix(name, "env."))
+		}
+		if strings.HasPrefix(name, "self.") {
+			if self == nil {
+				return "", fmt.Errorf("${%s} can only be referenced in the fields of a component", name)
+			}
+			// The component itself is used directly since its name alone is ambiguous when the stack
+			// has several versions of it
+			return i.lookupComponent(self, "self", strings.TrimPrefix(name, "self."))
+		}
+		if idx := strings.LastIndex(name, "."); idx != -1 {
+			if comp := i.c.getComponent(name[:idx]); comp != nil {
+				return i.lookupComponent(comp, name[:idx], name[idx+1:])
+			}
+		}
+		return i.lookupVar(name)
+	}
+}
+
+// interpolate replaces all the references to variables in the fields of the components. The following
+// variables are available:
+//   - ${stack.name}: the name of the stack;
+//   - ${stack.install_dir}: the installation directory from the configuration;
+//   - ${<component>.install_dir} and ${self.install_dir}: the installation directory of a component;
+//   - ${<component>.version} and ${self.version}: the version of a component;
+//   - ${env.NAME}: the value of an environment variable listed in env_vars;
+//   - ${name}: a variable from vars, which can itself reference other variables.
+//
+// Referencing a variable that is not defined is an error.
+func (c *Config) interpolate() error {
+	i := &interpolation{c: c, vars: make(map[string]string)}
+
+	// Versions are resolved first so they can be referenced by all the other fields
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		value, err := interpolateString(comp.Version, i.lookup(comp))
+		if err != nil {
+			return fmt.Errorf("component %s: version: %w", comp.Name, err)
+		}
+		comp.Version = value
+	}
+	i.versionsResolved = true
+
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		fields := []componentField{
+			{"URL", &comp.URL},
+			{"branch", &comp.Branch},
+			{"branch_checkout_prelude", &comp.BranchCheckoutPrelude},
+			{"configure_prelude", &comp.ConfigurePrelude},
+			{"configure_params", &comp.ConfigureParams},
+			{"build_script", &comp.BuildScript},
+			{"install_cmd", &comp.InstallCmd},
+		}
+		for idx := range comp.MakeExtraArgs {
+			fields = append(fields, componentField{"make_extra_args", &comp.MakeExtraArgs[idx]})
+		}
+		for idx := range comp.Env {
+			fields = append(fields, componentField{"env", &comp.Env[idx]})
+		}
+		for _, f := range fields {
+			value, err := interpolateString(*f.value, i.lookup(comp))
+			if err != nil {
+				return fmt.Errorf("component %s: %s: %w", comp.Name, f.name, err)
+			}
+			*f.value = value
+		}
+		if comp.BuildScript != "" && comp.buildScriptDir != "" && !filepath.IsAbs(comp.BuildScript) {
+			comp.BuildScript = filepath.Join(comp.buildScriptDir, comp.BuildScript)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	cfgPath := filepath.Join(dir, "cfg.json")
+	err = ioutil.WriteFile(cfgPath, []byte(`{"installDir": "/opt"}`), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", cfgPath, err)
+	}
+	os.Setenv("GO_SOFTWARE_BUILD_TEST_MIRROR", "https://mirror.example.com")
+	defer os.Unsetenv("GO_SOFTWARE_BUILD_TEST_MIRROR")
+
+	tests := []struct {
+		name          string
+		def           string
+		expectedError string
+	}{
+		{
+			name: "valid",
+			def: `{
+	"name": "test",
+	"vars": {"hwloc_major": "2", "hwloc_release": "${hwloc_major}.9", "ompi_url": "${env.GO_SOFTWARE_BUILD_TEST_MIRROR}/ompi"},
+	"env_vars": ["GO_SOFTWARE_BUILD_TEST_MIRROR"],
+	"components": [
+		{"name": "hwloc", "version": "${hwloc_release}.0", "URL": "${env.GO_SOFTWARE_BUILD_TEST_MIRROR}/hwloc-${self.version}.tar.gz"},
+		{"name": "ompi", "URL": "${ompi_url}", "configure_dependency": "hwloc", "configure_params": "--with-hwloc=${hwloc.install_dir} --with-platform=${stack.install_dir}/${stack.name}/hwloc-${hwloc.version}", "configure_prelude": "echo $${HOME}"}
+	]
+}`,
+		},
+		{
+			name:          "undefined",
+			def:           `{"name": "test", "components": [{"name": "hwloc", "URL": "file:///${prefix}/hwloc"}]}`,
+			expectedError: "component hwloc: URL: undefined variable ${prefix}",
+		},
+		{
+			name:          "env not allowed",
+			def:           `{"name": "test", "components": [{"name": "hwloc", "URL": "${env.GO_SOFTWARE_BUILD_TEST_MIRROR}/hwloc"}]}`,
+			expectedError: "component hwloc: URL: environment variable GO_SOFTWARE_BUILD_TEST_MIRROR is not listed in env_vars",
+		},
+		{
+			name:          "cycle",
+			def:           `{"name": "test", "vars": {"a": "${b}", "b": "${a}"}, "components": [{"name": "hwloc", "URL": "${a}"}]}`,
+			expectedError: "component hwloc: URL: variable ${a} references itself: a -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		c := &Config{DefFilePath: filepath.Join(dir, tt.name+".json"), ConfigFilePath: cfgPath}
+		err := ioutil.WriteFile(c.DefFilePath, []byte(tt.def), 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", c.DefFilePath, err)
+		}
+		err = c.Load()
+		if tt.expectedError != "" {
+			if err == nil || !strings.HasSuffix(err.Error(), tt.expectedError) {
+				t.Fatalf("%s: error is %v instead of %s", tt.name, err, tt.expectedError)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unable to load the stack: %s", tt.name, err)
+		}
+
+		hwloc := c.getComponent("hwloc")
+		ompi := c.getComponent("ompi")
+		expected := []struct {
+			value    string
+			expected string
+		}{
+			{hwloc.Version, "2.9.0"},
+			{hwloc.URL, "https://mirror.example.com/hwloc-2.9.0.tar.gz"},
+			{ompi.URL, "https://mirror.example.com/ompi"},
+			{ompi.ConfigureParams, "--with-hwloc=" + c.getComponentInstallDir(hwloc) + " --with-platform=/opt/test/hwloc-2.9.0"},
+			{ompi.ConfigurePrelude, "echo ${HOME}"},
+		}
+		for _, e := range expected {
+			if e.value != e.expected {
+				t.Fatalf("%s: value is %s instead of %s", tt.name, e.value, e.expected)
+			}
+		}
+	}
+
+	// self references the component itself when the stack has several versions of it
+	c := &Config{DefFilePath: filepath.Join(dir, "versions.json"), ConfigFilePath: cfgPath}
+	def := `{
+	"name": "test",
+	"components": [
+		{"name": "hwloc", "version": "2.9.0", "URL": "https://example.com/hwloc-${self.version}.tar.gz", "configure_params": "--prefix=${self.install_dir}"},
+		{"name": "hwloc", "version": "1.11.0", "URL": "https://example.com/hwloc-${self.version}.tar.gz", "configure_params": "--prefix=${self.install_dir}"}
+	]
+}`
+	err = ioutil.WriteFile(c.DefFilePath, []byte(def), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", c.DefFilePath, err)
+	}
+	err = c.Load()
+	if err != nil {
+		t.Fatalf("unable to load the stack with several versions: %s", err)
+	}
+	for _, version := range []string{"2.9.0", "1.11.0"} {
+		hwloc := c.getComponent("hwloc@" + version)
+		if hwloc == nil {
+			t.Fatalf("hwloc@%s is not defined", version)
+		}
+		if hwloc.URL != "https://example.com/hwloc-"+version+".tar.gz" {
+			t.Fatalf("URL of hwloc@%s is %s", version, hwloc.URL)
+		}
+		if hwloc.ConfigureParams != "--prefix="+c.getComponentInstallDir(hwloc) {
+			t.Fatalf("configure parameters of hwloc@%s are %s", version, hwloc.ConfigureParams)
+		}
+	}
+}
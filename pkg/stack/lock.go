@@ -0,0 +1,233 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	lockFileName = "stack.lock"
+)
+
+var commitRegexp = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// LockedComponent is the immutable reference to the source code of a component
+type LockedComponent struct {
+	// Name is the identifier of the component, i.e., name@version for a component with a version
+	Name string `json:"name"`
+
+	// URL and Branch are the values from the definition when the component was locked, used to detect
+	// when the lock is out of date
+	URL    string `json:"URL"`
+	Branch string `json:"branch,omitempty"`
+
+	// Commit is the commit of the branch when the source code is a Git repository
+	Commit string `json:"commit,omitempty"`
+
+	// Digest is the digest of the tarball when the source code is a tarball
+	Digest string `json:"digest,omitempty"`
+}
+
+// LockFile is the content of the lock file of a stack
+type LockFile struct {
+	Components []LockedComponent `json:"components"`
+}
+
+func (l *LockFile) get(name string) *LockedComponent {
+	for idx := range l.Components {
+		if l.Components[idx].Name == name {
+			return &l.Components[idx]
+		}
+	}
+	return nil
+}
+
+// GetLockFilePath returns the path to the lock file of the stack, next to the definition of the stack
+func (c *Config) GetLockFilePath() string {
+	return filepath.Join(filepath.Dir(c.DefFilePath), lockFileName)
+}
+
+// resolveGitCommit returns the commit a branch, a tag or HEAD of a remote Git repository points to
+func resolveGitCommit(ctx context.Context, url string, ref string) (string, error) {
+	if commitRegexp.MatchString(ref) {
+		return ref, nil
+	}
+	// Only the references the name can designate are considered, so "main" never matches a branch
+	// such as "feature/main", with branches first as when cloning the repository
+	var refs []string
+	switch {
+	case ref == "" || ref == "HEAD":
+		ref = "HEAD"
+		refs = []string{ref}
+	case strings.HasPrefix(ref, "refs/"):
+		refs = []string{ref + "^{}", ref}
+	default:
+		refs = []string{"refs/heads/" + ref, "refs/tags/" + ref + "^{}", "refs/tags/" + ref}
+	}
+	gitBin, err := exec.LookPath("git")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := process.Command(ctx, gitBin, append([]string{"ls-remote", url}, refs...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+	}
+
+	commits := make(map[string]string)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		tokens := strings.Fields(line)
+		if len(tokens) != 2 {
+			continue
+		}
+		commits[tokens[1]] = tokens[0]
+	}
+	// The commit of an annotated tag is the one of the peeled reference, which comes before the tag
+	for _, r := range refs {
+		if commit, ok := commits[r]; ok {
+			return commit, nil
+		}
+	}
+	return "", fmt.Errorf("%s does not exist in %s", ref, url)
+}
+
+// downloadDigest downloads a file and returns its digest
+func downloadDigest(ctx context.Context, url string) (string, error) {
+	binPath, err := exec.LookPath("wget")
+	if err != nil {
+		return "", fmt.Errorf("cannot find wget: %s", err)
+	}
+	tmpFile, err := ioutil.TempFile("", "")
+	if err != nil {
+		return "", fmt.Errorf("unable to create temporary file: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	var stdout, stderr bytes.Buffer
+	cmd := process.Command(ctx, binPath, "-q", "-O", tmpFile.Name(), url)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+	}
+	return buildenv.FileDigest(tmpFile.Name())
+}
+
+// resolveSource returns the immutable reference to the source code of a component. Local directories
+// cannot be locked so the reference of a component from a local directory has neither a commit nor a
+// digest.
+func resolveSource(ctx context.Context, comp *Component) (*LockedComponent, error) {
+	locked := &LockedComponent{Name: comp.id(), URL: comp.URL, Branch: comp.Branch}
+	var err error
+	switch util.DetectURLType(comp.URL) {
+	case util.GitURL:
+		locked.Commit, err = resolveGitCommit(ctx, comp.URL, comp.Branch)
+	case util.HttpURL:
+		locked.Digest, err = downloadDigest(ctx, comp.URL)
+	case util.FileURL:
+		path := strings.TrimPrefix(comp.URL, "file://")
+		if util.IsDir(path) {
+			log.Printf("%s is a local directory, %s cannot be locked", path, comp.Name)
+			return locked, nil
+		}
+		locked.Digest, err = buildenv.FileDigest(path)
+	default:
+		return nil, fmt.Errorf("impossible to detect the type of URL %s", comp.URL)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock %s: %w", comp.Name, err)
+	}
+	return locked, nil
+}
+
+// Lock resolves the source code of every component of the stack to an immutable reference and writes
+// them in the lock file of the stack
+func (c *Config) Lock() error {
+	return c.LockContext(context.Background())
+}
+
+// LockContext resolves the source code of every component of the stack to an immutable reference and
+// writes them in the lock file of the stack, stopping when the context is done
+func (c *Config) LockContext(ctx context.Context) error {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+
+	lock := new(LockFile)
+	for idx := range c.StackDefinition.Components {
+		locked, err := resolveSource(ctx, &c.StackDefinition.Components[idx])
+		if err != nil {
+			return err
+		}
+		lock.Components = append(lock.Components, *locked)
+	}
+
+	content, err := json.MarshalIndent(lock, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the lock of the stack: %w", err)
+	}
+	lockFilePath := c.GetLockFilePath()
+	err = ioutil.WriteFile(lockFilePath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", lockFilePath, err)
+	}
+	c.lock = lock
+	return nil
+}
+
+// loadLock loads the lock file of the stack, if it exists, and makes sure it matches the definition
+func (c *Config) loadLock() error {
+	c.lock = nil
+	lockFilePath := c.GetLockFilePath()
+	if c.DefFilePath == "" || !util.FileExists(lockFilePath) {
+		return nil
+	}
+	content, err := ioutil.ReadFile(lockFilePath)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", lockFilePath, err)
+	}
+	lock := new(LockFile)
+	err = json.Unmarshal(content, lock)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal content of %s: %w", lockFilePath, err)
+	}
+
+	for _, comp := range c.StackDefinition.Components {
+		locked := lock.get(comp.id())
+		if locked == nil {
+			return fmt.Errorf("%s is not in %s, the lock must be updated", comp.id(), lockFilePath)
+		}
+		if locked.URL != comp.URL || locked.Branch != comp.Branch {
+			return fmt.Errorf("the source of %s changed since it was locked in %s, the lock must be updated", comp.id(), lockFilePath)
+		}
+	}
+	c.lock = lock
+	return nil
+}
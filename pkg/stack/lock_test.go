@@ -0,0 +1,133 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A Git repository and a tarball, both served locally
+	repoPath, workDir, branch := createLocalGitComponent(t, dir)
+	lockedCommit := runGit(t, workDir, "rev-parse", "HEAD")
+	tarballPath := filepath.Join(dir, "hello-1.0.tar.gz")
+	runGit(t, workDir, "archive", "-o", tarballPath, "HEAD")
+
+	files := map[string]string{
+		"cfg.json": `{"installDir": "` + filepath.Join(dir, "install") + `"}`,
+		"def.json": `{"name": "test", "components": [{"name": "hello", "URL": "` + repoPath + `", "branch": "` + branch + `"}, {"name": "tarball", "URL": "file://` + tarballPath + `"}]}`,
+	}
+	for name, content := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", name, err)
+		}
+	}
+	c := &Config{DefFilePath: filepath.Join(dir, "def.json"), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+	err = c.Lock()
+	if err != nil {
+		t.Fatalf("unable to lock the stack: %s", err)
+	}
+	err = c.loadLock()
+	if err != nil {
+		t.Fatalf("unable to load %s: %s", c.GetLockFilePath(), err)
+	}
+	if c.lock.get("hello").Commit != lockedCommit || !strings.HasPrefix(c.lock.get("tarball").Digest, "sha256:") {
+		t.Fatalf("invalid lock: %+v", c.lock)
+	}
+
+	// New commits are ignored until the lock is updated
+	pushLocalGitCommit(t, workDir, repoPath, branch)
+	c.StackDefinition.Components = c.StackDefinition.Components[:1]
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	srcDir := filepath.Join(c.getStackBasedir(), "build", "hello", "hello")
+	if util.FileExists(filepath.Join(srcDir, "VERSION")) {
+		t.Fatalf("the locked commit was not honored")
+	}
+
+	os.RemoveAll(c.getStackBasedir())
+	c.UpdateLock = true
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	if !util.FileExists(filepath.Join(srcDir, "VERSION")) {
+		t.Fatalf("the lock was not updated")
+	}
+
+	// A tarball that does not match its digest is rejected
+	c = &Config{DefFilePath: filepath.Join(dir, "def.json"), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+	err = c.Lock()
+	if err != nil {
+		t.Fatalf("unable to lock the stack: %s", err)
+	}
+	runGit(t, workDir, "archive", "-o", tarballPath, "HEAD")
+	c.KeepGoing = true
+	report, err := c.InstallStackWithReport(context.Background())
+	if err == nil || report.Get("tarball").Stage != "fetch" || !strings.Contains(err.Error(), "digest of") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestResolveGitCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	repoPath, workDir, branch := createLocalGitComponent(t, dir)
+	first := runGit(t, workDir, "rev-parse", "HEAD")
+	// Branches and tags whose name ends like another reference must not be mistaken for it
+	runGit(t, workDir, "tag", "-a", "-m", "v1", "v1")
+	runGit(t, workDir, "push", "-q", repoPath, "v1")
+	pushLocalGitCommit(t, workDir, repoPath, branch)
+	second := runGit(t, workDir, "rev-parse", "HEAD")
+	runGit(t, workDir, "push", "-q", repoPath, "HEAD:refs/heads/feature/"+branch)
+	runGit(t, workDir, "push", "-q", repoPath, "HEAD:refs/heads/old/v1")
+
+	tests := []struct {
+		ref    string
+		commit string
+	}{
+		{ref: "", commit: second},
+		{ref: branch, commit: second},
+		{ref: "v1", commit: first},
+		{ref: "refs/tags/v1", commit: first},
+		{ref: first, commit: first},
+	}
+	for _, tt := range tests {
+		commit, err := resolveGitCommit(context.Background(), repoPath, tt.ref)
+		if err != nil {
+			t.Fatalf("unable to resolve %q: %s", tt.ref, err)
+		}
+		if commit != tt.commit {
+			t.Fatalf("%q resolved to %s instead of %s", tt.ref, commit, tt.commit)
+		}
+	}
+
+	_, err = resolveGitCommit(context.Background(), repoPath, "feature")
+	if err == nil {
+		t.Fatalf("the prefix of a branch was resolved")
+	}
+}
@@ -0,0 +1,57 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/lock"
+)
+
+const (
+	// stackLockFileName is the name of the file, in the base directory of the stack, locked while the
+	// stack is modified
+	stackLockFileName = ".install.lock"
+
+	// componentLockDirName is the name of the directory, in the base directory of the stack, with the
+	// files locked while individual components are installed
+	componentLockDirName = "locks"
+)
+
+// lockStack acquires the lock of the stack, making sure no other process modifies the stack at the same
+// time. When shared is true, other processes can also hold a shared lock, i.e., install the stack while
+// locking individual components.
+func (c *Config) lockStack(ctx context.Context, shared bool) (*lock.FileLock, error) {
+	stackBasedir := c.getStackBasedir()
+	err := os.MkdirAll(stackBasedir, defaultPermission)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", stackBasedir, err)
+	}
+	l, err := lock.AcquireContext(ctx, filepath.Join(stackBasedir, stackLockFileName), lock.Options{Shared: shared, Wait: c.LockWait})
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock stack %s: %w", c.StackDefinition.Name, err)
+	}
+	return l, nil
+}
+
+// lockComponent acquires the lock of a component, making sure no other process installs the component
+// at the same time
+func (c *Config) lockComponent(ctx context.Context, name string) (*lock.FileLock, error) {
+	lockDir := filepath.Join(c.getStackBasedir(), componentLockDirName)
+	err := os.MkdirAll(lockDir, defaultPermission)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", lockDir, err)
+	}
+	l, err := lock.AcquireContext(ctx, filepath.Join(lockDir, name+".lock"), lock.Options{Wait: c.LockWait})
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock component %s: %w", name, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,97 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/lock"
+)
+
+func TestStackLocking(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "")},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+
+	// Another installation of the stack is simulated by holding the lock of the stack
+	other, err := c.lockStack(context.Background(), false)
+	if err != nil {
+		t.Fatalf("unable to lock the stack: %s", err)
+	}
+	err = c.InstallStackContext(context.Background())
+	var lockedErr *lock.LockedError
+	if !errors.As(err, &lockedErr) || lockedErr.Holder == nil || lockedErr.Holder.PID != os.Getpid() || lockedErr.Holder.Stale() {
+		t.Fatalf("installing a locked stack did not fail with the holder of the lock: %v", err)
+	}
+
+	// The installation can also wait for the lock to be released
+	c.LockWait = true
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		other.Release()
+	}()
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack once the lock is released: %s", err)
+	}
+
+	// With component locks, only the locked component cannot be installed
+	c.LockWait = false
+	c.ComponentLocks = true
+	err = c.Remove("hwloc")
+	if err != nil {
+		t.Fatalf("unable to remove hwloc: %s", err)
+	}
+	other, err = c.lockComponent(context.Background(), "hwloc")
+	if err != nil {
+		t.Fatalf("unable to lock hwloc: %s", err)
+	}
+	defer other.Release()
+	err = ioutil.WriteFile(c.GetComponentLogPath("hwloc"), []byte("installation in progress\n"), 0644)
+	if err != nil {
+		t.Fatalf("unable to write the log of hwloc: %s", err)
+	}
+	c.KeepGoing = true
+	report, err := c.InstallStackWithReport(context.Background())
+	if err == nil || report.Get("hwloc").Status != StatusFailed || report.Get("ucx").Status != StatusSucceeded {
+		t.Fatalf("unexpected installation of the stack with a locked component (%v):\n%s", err, report)
+	}
+	hwlocLog, err := ioutil.ReadFile(c.GetComponentLogPath("hwloc"))
+	if err != nil || string(hwlocLog) != "installation in progress\n" {
+		t.Fatalf("the log of the installation holding the lock was modified (%v): %s", err, hwlocLog)
+	}
+
+	// A holder that exited without releasing the lock is detected
+	cmd := exec.Command("true")
+	err = cmd.Run()
+	if err != nil {
+		t.Fatalf("unable to run true: %s", err)
+	}
+	host, _ := os.Hostname()
+	holder := &lock.Holder{PID: cmd.Process.Pid, Host: host}
+	if !holder.Stale() {
+		t.Fatalf("the holder of the lock is not stale while the process exited")
+	}
+}
@@ -0,0 +1,184 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	recordDirName    = "records"
+	recordFileSuffix = ".json"
+)
+
+// componentRecord is what we know about the last installation of a component, saved on disk
+type componentRecord struct {
+	// Status is the outcome of the last installation
+	Status ComponentStatus `json:"status"`
+
+	// Time is when the last installation completed
+	Time time.Time `json:"time"`
+
+	// Stage is the stage that failed when the installation failed
+	Stage builder.Stage `json:"stage,omitempty"`
+
+	// Error is the error returned when the installation failed
+	Error string `json:"error,omitempty"`
+
+	// URL and Branch are the source of the component from its definition
+	URL    string `json:"URL"`
+	Branch string `json:"branch,omitempty"`
+
+	// Commit is the commit that was built when the source code is a Git repository
+	Commit string `json:"commit,omitempty"`
+
+	// Digest is the digest of the tarball that was built when the source code is a tarball
+	Digest string `json:"digest,omitempty"`
+
+	// DefinitionHash is the hash of the definition of the component
+	DefinitionHash string `json:"definition_hash"`
+
+	// InputHash is the hash of all the inputs of the installation, including the ones of the dependencies
+	InputHash string `json:"input_hash,omitempty"`
+
+	// SrcDir is the directory where the source code was built, the local mirror for a Git repository
+	SrcDir string `json:"src_dir,omitempty"`
+}
+
+func (c *Config) getRecordDir() string {
+	// Every generation has its own records since they describe what the generation includes
+	if c.Generations {
+		return filepath.Join(c.getInstallRoot(), generationRecordDirName)
+	}
+	return filepath.Join(c.getStackBasedir(), recordDirName)
+}
+
+func (c *Config) getRecordPath(name string) string {
+	return filepath.Join(c.getRecordDir(), name+recordFileSuffix)
+}
+
+// definitionHash returns the hash of the definition of the component
+func (comp *Component) definitionHash() string {
+	content, err := json.Marshal(comp)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// loadRecord returns the record of a component, nil if the component was never installed
+func (c *Config) loadRecord(name string) (*componentRecord, error) {
+	path := c.getRecordPath(name)
+	if !util.FileExists(path) {
+		return nil, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	record := new(componentRecord)
+	err = json.Unmarshal(content, record)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal content of %s: %w", path, err)
+	}
+	return record, nil
+}
+
+func (c *Config) saveRecord(name string, record *componentRecord) error {
+	err := os.MkdirAll(c.getRecordDir(), defaultPermission)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", c.getRecordDir(), err)
+	}
+	content, err := json.MarshalIndent(record, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal the record of %s: %w", name, err)
+	}
+	path := c.getRecordPath(name)
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return fmt.Errorf("unable to move %s to %s: %w", tmpPath, path, err)
+	}
+	return nil
+}
+
+// runGitCmd executes a Git command from a directory and returns its output
+func runGitCmd(ctx context.Context, dir string, args ...string) (string, error) {
+	gitBin, err := exec.LookPath("git")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git: %w", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := process.Command(ctx, gitBin, args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// newRecord returns the record of an installation of a component that just completed
+func (c *Config) newRecord(ctx context.Context, comp *Component, b *builder.Builder, installErr error) *componentRecord {
+	record := &componentRecord{
+		Status:         StatusSucceeded,
+		Time:           time.Now(),
+		URL:            comp.URL,
+		Branch:         comp.Branch,
+		DefinitionHash: comp.definitionHash(),
+		InputHash:      c.inputs[comp.id()],
+	}
+	if installErr != nil {
+		record.Status = StatusFailed
+		record.Error = installErr.Error()
+		var stageErr *builder.StageError
+		if errors.As(installErr, &stageErr) {
+			record.Stage = stageErr.Stage
+		}
+	}
+	if b == nil {
+		return record
+	}
+	record.SrcDir = b.Env.SrcDir
+
+	// The revision that was actually built, which may not be the one from the lock
+	switch util.DetectURLType(comp.URL) {
+	case util.GitURL:
+		if util.IsDir(b.Env.SrcDir) {
+			record.Commit, _ = runGitCmd(ctx, b.Env.SrcDir, "rev-parse", "HEAD")
+		}
+	default:
+		if b.Env.SrcPath != "" && util.FileExists(b.Env.SrcPath) && !util.IsDir(b.Env.SrcPath) {
+			record.Digest, _ = buildenv.FileDigest(b.Env.SrcPath)
+		}
+	}
+	return record
+}
@@ -0,0 +1,175 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// getTransitiveDependents returns the name of all the components that directly or indirectly depend on
+// a component, in an order where every component comes before all its dependencies
+func getTransitiveDependents(graph *Graph, installOrder []string, name string) []string {
+	dependents := make(map[string]bool)
+	queue := []string{name}
+	for len(queue) > 0 {
+		for _, dependent := range graph.Dependents(queue[0]) {
+			if !dependents[dependent] {
+				dependents[dependent] = true
+				queue = append(queue, dependent)
+			}
+		}
+		queue = queue[1:]
+	}
+
+	var list []string
+	for idx := len(installOrder) - 1; idx >= 0; idx-- {
+		if dependents[installOrder[idx]] {
+			list = append(list, installOrder[idx])
+		}
+	}
+	return list
+}
+
+// Remove deletes everything the stack created for a component: its installation, its build and source
+// directories, its modulefile, its log and its record. See RemoveContext for details.
+func (c *Config) Remove(name string) error {
+	return c.RemoveContext(context.Background(), name)
+}
+
+// RemoveContext deletes everything the stack created for a component: its installation, its build and
+// source directories, its modulefile, its log and its record. The component is designated by its name,
+// or by name@version when the stack has several versions of it. The removal is refused when installed
+// components depend on the component, unless the configuration requests to cascade, in which case all
+// the components depending on it are removed first. With generations, the components are only removed
+// from a new generation: the previous generations still have them until they are collected, and their
+// modulefiles, builds and logs are kept so rolling back restores them.
+func (c *Config) RemoveContext(ctx context.Context, name string) error {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+
+	comp, err := c.resolveComponent(name)
+	if err != nil {
+		return err
+	}
+	name = comp.id()
+	graph, err := c.Graph()
+	if err != nil {
+		return fmt.Errorf("invalid stack definition: %w", err)
+	}
+	installOrder, err := graph.TopologicalOrder()
+	if err != nil {
+		return fmt.Errorf("invalid stack definition: %w", err)
+	}
+
+	stackLock, err := c.lockStack(ctx, false)
+	if err != nil {
+		return err
+	}
+	defer stackLock.Release()
+
+	dependents := getTransitiveDependents(graph, installOrder, name)
+	if !c.Cascade {
+		var installed []string
+		for _, dependent := range dependents {
+			if util.PathExists(c.getComponentInstallDir(c.getComponent(dependent))) {
+				installed = append(installed, dependent)
+			}
+		}
+		if len(installed) > 0 {
+			return fmt.Errorf("unable to remove %s, the following installed components depend on it: %s", name, strings.Join(installed, ", "))
+		}
+		dependents = nil
+	}
+
+	removed := append(dependents, name)
+	if c.Generations {
+		// The current generation is never modified, the components are removed by switching to a new
+		// generation without them. Everything else, e.g., the modulefiles, which follow the current
+		// generation, and the build directories, is kept since rolling back brings the components back.
+		number, err := c.removeFromGeneration(removed)
+		if err != nil {
+			return err
+		}
+		if number == 0 {
+			return nil
+		}
+		c.generation = number
+		defer func() {
+			c.generation = 0
+		}()
+		return c.activateGeneration()
+	}
+
+	records := make(map[string]*componentRecord)
+	for _, compName := range removed {
+		records[compName], err = c.loadRecord(compName)
+		if err != nil {
+			return err
+		}
+	}
+	for _, compName := range removed {
+		err := c.removeComponent(ctx, compName, records[compName])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeComponent deletes everything the stack created for a single component, record being the
+// record of its installation, if any
+func (c *Config) removeComponent(ctx context.Context, name string, record *componentRecord) error {
+	log.Printf("Removing %s...", name)
+	comp := c.getComponent(name)
+	b, err := c.newComponentBuilder(comp)
+	if err != nil {
+		return err
+	}
+	// Entries of the store may be used by other stacks, only the link from the stack is removed and the
+	// entry is left to the garbage collection of the store
+	b.Env.InstallDir = c.getInstallRoot()
+	err = b.Remove(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to remove %s: %w", name, err)
+	}
+
+	stackBasedir := c.getStackBasedir()
+	srcDir := filepath.Join(stackBasedir, "src")
+	paths := []string{
+		filepath.Join(stackBasedir, "modulefiles", comp.getPath()),
+		c.GetComponentLogPath(name),
+		c.getRecordPath(name),
+	}
+	if util.DetectURLType(comp.URL) == util.HttpURL {
+		paths = append(paths, filepath.Join(srcDir, path.Base(comp.URL)))
+	}
+	// The source code unpacked in the source directory shared by all the components
+	if record != nil && strings.HasPrefix(record.SrcDir, srcDir+string(filepath.Separator)) {
+		paths = append(paths, record.SrcDir)
+	}
+	for _, p := range paths {
+		if util.PathExists(p) {
+			err := os.RemoveAll(p)
+			if err != nil {
+				return fmt.Errorf("unable to remove %s: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
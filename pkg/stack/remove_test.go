@@ -0,0 +1,84 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestRemove(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+			{Name: "pmix", URL: createLocalComponent(t, srcDir, "pmix", ""), ConfigureDependency: "hwloc"},
+			{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "pmix"},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "")},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+
+	installed := func(name string) bool {
+		return util.PathExists(c.getComponentInstallDir(c.getComponent(name)))
+	}
+	buildDir := func(name string) string {
+		return filepath.Join(c.getStackBasedir(), "build", name)
+	}
+
+	err = c.Remove("hwloc")
+	if err == nil || !strings.Contains(err.Error(), "ompi, pmix") {
+		t.Fatalf("removing hwloc did not fail because of its dependents: %v", err)
+	}
+	if !installed("hwloc") {
+		t.Fatalf("hwloc was removed while the removal failed")
+	}
+
+	err = c.Remove("ompi")
+	if err != nil {
+		t.Fatalf("unable to remove ompi: %s", err)
+	}
+	if installed("ompi") || util.PathExists(buildDir("ompi")) || util.PathExists(c.getRecordPath("ompi")) {
+		t.Fatalf("ompi was not fully removed")
+	}
+
+	c.Cascade = true
+	err = c.Remove("hwloc")
+	if err != nil {
+		t.Fatalf("unable to remove hwloc and its dependents: %s", err)
+	}
+	for _, name := range []string{"hwloc", "pmix"} {
+		if installed(name) || util.PathExists(buildDir(name)) {
+			t.Fatalf("%s was not removed", name)
+		}
+	}
+	if !installed("ucx") || !util.PathExists(buildDir("ucx")) {
+		t.Fatalf("ucx was removed while it does not depend on hwloc")
+	}
+
+	err = c.Remove("unknown")
+	if err == nil {
+		t.Fatalf("removing a component that is not part of the stack succeeded")
+	}
+}
@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+)
+
+// ComponentStatus is the outcome of the installation of a component
+type ComponentStatus string
+
+const (
+	// StatusSucceeded means that the component was successfully installed
+	StatusSucceeded ComponentStatus = "succeeded"
+
+	// StatusFailed means that the installation of the component failed
+	StatusFailed ComponentStatus = "failed"
+
+	// StatusSkipped means that the component was not installed because one of its dependencies failed
+	StatusSkipped ComponentStatus = "skipped"
+)
+
+// ComponentResult is the outcome of the installation of a single component
+type ComponentResult struct {
+	// Name is the identifier of the component, i.e., name@version for a component with a version
+	Name string
+
+	// Status is the outcome of the installation
+	Status ComponentStatus
+
+	// Stage is the stage that failed, empty when the component did not fail or when it failed outside
+	// of the stages of the installation
+	Stage builder.Stage
+
+	// FailedDependency is the name of the dependency that failed when the component was skipped
+	FailedDependency string
+
+	// LogPath is the path to the log of the installation, empty when the installation was never started
+	LogPath string
+
+	// Err is the error returned by the installation when it failed
+	Err error
+}
+
+// InstallReport is the outcome of the installation of all the components of a stack, in install order
+type InstallReport struct {
+	Components []ComponentResult
+
+	// failureOrder is the name of the components that failed, in the order they failed
+	failureOrder []string
+}
+
+// InstallError is the error returned when one or more components of a stack failed to install
+type InstallError struct {
+	// Failures are the results of all the components that failed, in the order they failed
+	Failures []ComponentResult
+}
+
+func (e *InstallError) Error() string {
+	var msgs []string
+	for _, f := range e.Failures {
+		msgs = append(msgs, fmt.Sprintf("unable to install %s: %s", f.Name, f.Err))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the error of the first component that failed
+func (e *InstallError) Unwrap() error {
+	return e.Failures[0].Err
+}
+
+func newComponentResult(name string, logPath string, err error) ComponentResult {
+	res := ComponentResult{
+		Name:    name,
+		Status:  StatusSucceeded,
+		LogPath: logPath,
+	}
+	if err != nil {
+		res.Status = StatusFailed
+		res.Err = err
+		var stageErr *builder.StageError
+		if errors.As(err, &stageErr) {
+			res.Stage = stageErr.Stage
+		}
+	}
+	return res
+}
+
+// Get returns the result of a given component, nil if the component is not part of the report
+func (r *InstallReport) Get(name string) *ComponentResult {
+	for idx := range r.Components {
+		if r.Components[idx].Name == name {
+			return &r.Components[idx]
+		}
+	}
+	return nil
+}
+
+// Err returns the aggregated error of all the components that failed, nil when none failed
+func (r *InstallReport) Err() error {
+	var failures []ComponentResult
+	for _, name := range r.failureOrder {
+		failures = append(failures, *r.Get(name))
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &InstallError{Failures: failures}
+}
+
+// String returns the report as a table
+func (r *InstallReport) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tSTATUS\tSTAGE\tLOG")
+	for _, res := range r.Components {
+		stage := string(res.Stage)
+		if res.Status == StatusSkipped {
+			stage = "dependency " + res.FailedDependency + " failed"
+		}
+		if stage == "" {
+			stage = "-"
+		}
+		logPath := res.LogPath
+		if logPath == "" {
+			logPath = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", res.Name, res.Status, stage, logPath)
+	}
+	w.Flush()
+	return buf.String()
+}
@@ -0,0 +1,188 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	logDirName    = "logs"
+	logFileSuffix = ".log"
+)
+
+func (c *Config) getLogDir() string {
+	return filepath.Join(c.getStackBasedir(), logDirName)
+}
+
+// GetComponentLogPath returns the path to the file where the output of the installation of a component is saved
+func (c *Config) GetComponentLogPath(name string) string {
+	return filepath.Join(c.getLogDir(), name+logFileSuffix)
+}
+
+func (c *Config) getConcurrency() int {
+	if c.Concurrency < 1 {
+		return 1
+	}
+	return c.Concurrency
+}
+
+// installComponent installs a single component of the stack, saving the entire output of the
+// installation in the component's log file
+func (c *Config) installComponent(ctx context.Context, name string) error {
+	// The log is only truncated once the lock is held so the log of an installation of the component
+	// by another process is left intact
+	if c.ComponentLocks {
+		componentLock, err := c.lockComponent(ctx, name)
+		if err != nil {
+			return err
+		}
+		defer componentLock.Release()
+	}
+
+	logPath := c.GetComponentLogPath(name)
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", logPath, err)
+	}
+	defer logFile.Close()
+
+	// When components are installed one at a time, the output is not mixed up so we still display it
+	var output io.Writer = logFile
+	if c.getConcurrency() == 1 {
+		output = io.MultiWriter(logFile, log.Writer())
+	}
+	ctx = process.WithOutput(ctx, output)
+
+	comp := c.getComponent(name)
+	b, err := c.newComponentBuilder(comp)
+	if err != nil {
+		process.Logf(ctx, "%s", err)
+		return err
+	}
+
+	record, err := c.loadRecord(name)
+	if err != nil {
+		process.Logf(ctx, "%s", err)
+	}
+	if c.useStore(comp) {
+		return c.installInStore(ctx, comp, b, record)
+	}
+
+	// An installed component is rebuilt only when its inputs changed since it was installed. Components
+	// installed without an input hash are left untouched since we cannot tell what they were built from.
+	alreadyInstalled := util.PathExists(c.getComponentInstallDir(comp))
+	if alreadyInstalled && record != nil && record.InputHash != "" && record.InputHash != c.inputs[name] {
+		process.Logf(ctx, "-> Inputs of %s changed since it was installed, rebuilding...", name)
+		err = b.RestartFrom(builder.StageFetch)
+		if err != nil {
+			return fmt.Errorf("unable to restart the installation of %s: %w", name, err)
+		}
+		b.Replace = true
+	}
+	res := b.InstallContext(ctx)
+	// The record of a component that was already installed describes the installation in place, which
+	// is still the previous one when its replacement failed
+	if !alreadyInstalled || record == nil || (b.Replace && res.Err == nil) {
+		err = c.saveRecord(name, c.newRecord(ctx, comp, b, res.Err))
+		if err != nil {
+			process.Logf(ctx, "unable to save the record of %s: %s", name, err)
+		}
+	}
+	if res.Err != nil {
+		process.Logf(ctx, "unable to install %s: %s", name, res.Err)
+		return res.Err
+	}
+	return nil
+}
+
+// schedule installs the components of the stack with a pool of workers. A component is started only
+// once all its dependencies are installed. When a component fails, its dependents are never started
+// but the components that do not depend on it are still installed.
+func (c *Config) schedule(ctx context.Context, graph *Graph, installOrder []string) *InstallReport {
+	results := make(map[string]ComponentResult)
+	var failureOrder []string
+	started := make(map[string]bool)
+	done := make(chan ComponentResult)
+	running := 0
+
+	for {
+		// Start as many components as possible, in the install order
+		for _, name := range installOrder {
+			if running >= c.getConcurrency() {
+				break
+			}
+			if started[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range graph.Dependencies[name] {
+				if res, ok := results[dep]; !ok || res.Status != StatusSucceeded {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+
+			started[name] = true
+			running++
+			log.Printf("-> Installing %s (log: %s)", name, c.GetComponentLogPath(name))
+			go func(name string) {
+				err := c.installComponent(ctx, name)
+				done <- newComponentResult(name, c.GetComponentLogPath(name), err)
+			}(name)
+		}
+
+		if running == 0 {
+			break
+		}
+
+		res := <-done
+		running--
+		results[res.Name] = res
+		if res.Status == StatusFailed {
+			log.Printf("-> Installation of %s failed, see %s for details", res.Name, res.LogPath)
+			failureOrder = append(failureOrder, res.Name)
+			continue
+		}
+		log.Printf("-> %s was successfully installed in %s", res.Name, c.getComponentInstallDir(c.getComponent(res.Name)))
+	}
+
+	report := &InstallReport{failureOrder: failureOrder}
+	for _, name := range installOrder {
+		res, ok := results[name]
+		if !ok {
+			// A component is only never started when one of its dependencies did not install. Dependencies
+			// are before the component in the install order so their result is already known.
+			res = ComponentResult{Name: name, Status: StatusSkipped}
+			for _, dep := range graph.Dependencies[name] {
+				depRes := report.Get(dep)
+				if depRes.Status == StatusFailed {
+					res.FailedDependency = dep
+					break
+				}
+				if depRes.Status == StatusSkipped {
+					res.FailedDependency = depRes.FailedDependency
+					break
+				}
+			}
+		}
+		report.Components = append(report.Components, res)
+	}
+	return report
+}
@@ -0,0 +1,194 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestParallelInstall(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	// hwloc and ucx can only complete if they are built at the same time, ompi can only be built
+	// once both are installed
+	hwlocStarted := filepath.Join(srcDir, "hwloc.started")
+	ucxStarted := filepath.Join(srcDir, "ucx.started")
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "ompi", ConfigureDependency: "hwloc,ucx"},
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "touch "+hwlocStarted+"; "+waitForFileHook(ucxStarted))},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "touch "+ucxStarted+"; "+waitForFileHook(hwlocStarted))},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+	c.Concurrency = 2
+	hwlocBinary := filepath.Join(c.getComponentInstallDir(c.getComponent("hwloc")), "bin", "helloworld")
+	ucxBinary := filepath.Join(c.getComponentInstallDir(c.getComponent("ucx")), "bin", "helloworld")
+	def.Components[0].URL = createLocalComponent(t, srcDir, "ompi", "test -f "+hwlocBinary+" && test -f "+ucxBinary)
+
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+
+	for _, comp := range def.Components {
+		expectedBinary := filepath.Join(c.getComponentInstallDir(&comp), "bin", "helloworld")
+		if !util.FileExists(expectedBinary) {
+			t.Fatalf("expected binary %s does not exist", expectedBinary)
+		}
+		content, err := ioutil.ReadFile(c.GetComponentLogPath(comp.Name))
+		if err != nil {
+			t.Fatalf("unable to read the log of %s: %s", comp.Name, err)
+		}
+		if !strings.Contains(string(content), "chmod +x helloworld") {
+			t.Fatalf("log of %s does not include the output of the build:\n%s", comp.Name, content)
+		}
+	}
+}
+
+func TestParallelInstallFailure(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	// ucx fails while hwloc is still being built, hwloc must complete but ompi must never start
+	ucxFailed := filepath.Join(srcDir, "ucx.failed")
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", waitForFileHook(ucxFailed))},
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "touch "+ucxFailed+"; exit 1")},
+			{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "ucx"},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+	c.Concurrency = 2
+
+	err = c.InstallStackContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "unable to install ucx") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !util.PathExists(c.getComponentInstallDir(c.getComponent("hwloc"))) {
+		t.Fatalf("hwloc was not installed")
+	}
+	if util.PathExists(c.GetComponentLogPath("ompi")) {
+		t.Fatalf("ompi was started even if its dependency failed")
+	}
+}
+
+func TestKeepGoing(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx", "exit 1")},
+			{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "ucx"},
+			{Name: "imb", URL: createLocalComponent(t, srcDir, "imb", ""), ConfigureDependency: "ompi"},
+			{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+			{Name: "pmix", URL: createLocalComponent(t, srcDir, "pmix", "exit 1"), ConfigureDependency: "hwloc"},
+		},
+	}
+
+	tests := []struct {
+		keepGoing        bool
+		expectedFailures []string
+	}{
+		{keepGoing: true, expectedFailures: []string{"ucx", "pmix"}},
+		{keepGoing: false, expectedFailures: []string{"ucx"}},
+	}
+
+	for _, tt := range tests {
+		c, cleanupFn := newTestConfig(t, def)
+		c.KeepGoing = tt.keepGoing
+		report, err := c.InstallStackWithReport(context.Background())
+		cleanupFn()
+
+		var installErr *InstallError
+		if !errors.As(err, &installErr) || len(installErr.Failures) != len(tt.expectedFailures) {
+			t.Fatalf("keep going: %t: unexpected error: %v", tt.keepGoing, err)
+		}
+		for idx, name := range tt.expectedFailures {
+			if installErr.Failures[idx].Name != name {
+				t.Fatalf("keep going: %t: unexpected error: %v", tt.keepGoing, err)
+			}
+		}
+		// Unrelated branches are installed whether or not we keep going
+		expected := []ComponentResult{
+			{Name: "ucx", Status: StatusFailed, Stage: builder.StageBuild},
+			{Name: "ompi", Status: StatusSkipped, FailedDependency: "ucx"},
+			{Name: "imb", Status: StatusSkipped, FailedDependency: "ucx"},
+			{Name: "hwloc", Status: StatusSucceeded},
+			{Name: "pmix", Status: StatusFailed, Stage: builder.StageBuild},
+		}
+		for _, e := range expected {
+			res := report.Get(e.Name)
+			if res == nil || res.Status != e.Status || res.Stage != e.Stage || res.FailedDependency != e.FailedDependency {
+				t.Fatalf("keep going: %t: unexpected result for %s: %+v\n%s", tt.keepGoing, e.Name, res, report)
+			}
+		}
+		if !strings.Contains(report.String(), "dependency ucx failed") {
+			t.Fatalf("keep going: %t: invalid summary:\n%s", tt.keepGoing, report)
+		}
+	}
+
+	// Failures are reported in the order they happened, not in the install order
+	failedFile := filepath.Join(srcDir, "pmix.failed")
+	def = &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "ucx", URL: createLocalComponent(t, srcDir, "ucx2", waitForFileHook(failedFile)+"; exit 1")},
+			{Name: "pmix", URL: createLocalComponent(t, srcDir, "pmix2", "touch "+failedFile+"; exit 1")},
+		},
+	}
+	for _, tt := range []struct {
+		keepGoing        bool
+		expectedFailures []string
+	}{
+		{keepGoing: true, expectedFailures: []string{"pmix", "ucx"}},
+		{keepGoing: false, expectedFailures: []string{"pmix"}},
+	} {
+		os.Remove(failedFile)
+		c, cleanupFn := newTestConfig(t, def)
+		c.KeepGoing = tt.keepGoing
+		c.Concurrency = 2
+		_, err := c.InstallStackWithReport(context.Background())
+		cleanupFn()
+
+		var installErr *InstallError
+		if !errors.As(err, &installErr) || len(installErr.Failures) != len(tt.expectedFailures) {
+			t.Fatalf("keep going: %t: unexpected error: %v", tt.keepGoing, err)
+		}
+		for idx, name := range tt.expectedFailures {
+			if installErr.Failures[idx].Name != name {
+				t.Fatalf("keep going: %t: failures are not in the order they happened: %v", tt.keepGoing, err)
+			}
+		}
+	}
+}
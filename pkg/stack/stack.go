@@ -8,7 +8,8 @@ package stack
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -16,31 +17,68 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/BTMichalowicz/go_software_build/internal/pkg/module"
+	"github.com/BTMichalowicz/go_software_build/pkg/buildenv"
 	"github.com/BTMichalowicz/go_software_build/pkg/builder"
 	"github.com/BTMichalowicz/go_util/pkg/util"
 )
 
 type StackCfg struct {
-	InstallDir string `json:"installDir"`
+	InstallDir string `json:"installDir" yaml:"installDir" toml:"installDir"`
 }
 
 type Component struct {
-	Name                  string `json:"name"`
-	URL                   string `json:"URL"`
-	Branch                string `json:"branch"`
-	BranchCheckoutPrelude string `json:"branch_checkout_prelude"`
-	ConfigId              string `json:"configure_id"`
-	ConfigureDependency   string `json:"configure_dependency"`
-	ConfigurePrelude      string `json:"configure_prelude"`
-	ConfigureParams       string `json:"configure_params"`
+	Name                  string `json:"name" yaml:"name" toml:"name"`
+	URL                   string `json:"URL" yaml:"URL,omitempty" toml:"URL,omitempty"`
+	Branch                string `json:"branch" yaml:"branch,omitempty" toml:"branch,omitempty"`
+	BranchCheckoutPrelude string `json:"branch_checkout_prelude" yaml:"branch_checkout_prelude,omitempty" toml:"branch_checkout_prelude,omitempty"`
+	ConfigId              string `json:"configure_id" yaml:"configure_id,omitempty" toml:"configure_id,omitempty"`
+	ConfigureDependency   string `json:"configure_dependency" yaml:"configure_dependency,omitempty" toml:"configure_dependency,omitempty"`
+	ConfigurePrelude      string `json:"configure_prelude" yaml:"configure_prelude,omitempty" toml:"configure_prelude,omitempty"`
+	ConfigureParams       string `json:"configure_params" yaml:"configure_params,omitempty" toml:"configure_params,omitempty"`
+	// Timeouts is the maximum duration of each stage of the installation of the component, e.g., {"build": "2h"}
+	Timeouts map[string]string `json:"timeouts" yaml:"timeouts,omitempty" toml:"timeouts,omitempty"`
+	// Version is the version of the component, which can be referenced by the other fields of the stack.
+	// A component with a version is installed in <name>/<version> and designated as name@version, so
+	// several versions of the same component can be installed side by side.
+	Version string `json:"version" yaml:"version,omitempty" toml:"version,omitempty"`
+	// BuildScript is the script used to build the component instead of make, relative to the definition's file
+	// once the variables it references are resolved
+	BuildScript string `json:"build_script" yaml:"build_script,omitempty" toml:"build_script,omitempty"`
+	// InstallCmd is the command used to install the component instead of 'make install'. It is executed
+	// from the source directory and must install the component under ${DESTDIR}
+	InstallCmd string `json:"install_cmd" yaml:"install_cmd,omitempty" toml:"install_cmd,omitempty"`
+	// SudoRequired specifies whether the component must be installed with sudo. Since only the fields set
+	// by a definition override the inherited ones, a definition cannot reset it to false.
+	SudoRequired bool `json:"sudo_required" yaml:"sudo_required,omitempty" toml:"sudo_required,omitempty"`
+	// MakeExtraArgs is the list of extra arguments passed to make, e.g., "CC=gcc-12". An empty list does
+	// not override the inherited arguments, a definition can only replace them with other arguments.
+	MakeExtraArgs []string `json:"make_extra_args" yaml:"make_extra_args,omitempty" toml:"make_extra_args,omitempty"`
+	// Env is the list of environment variables, with the "NAME=value" format, set to build the component.
+	// Like MakeExtraArgs, an empty list does not override the inherited variables.
+	Env []string `json:"env" yaml:"env,omitempty" toml:"env,omitempty"`
+	// AppendConfigureParams is added to the configure parameters inherited from a parent definition
+	AppendConfigureParams string `json:"append_configure_params" yaml:"append_configure_params,omitempty" toml:"append_configure_params,omitempty"`
+	// buildScriptDir is the directory of the definition that sets BuildScript
+	buildScriptDir string
 }
 
 type StackDef struct {
-	Name       string `json:"name"`
-	Type       string `json:"type"`
-	Components []Component
+	Name       string      `json:"name" yaml:"name" toml:"name"`
+	Type       string      `json:"type" yaml:"type,omitempty" toml:"type,omitempty"`
+	Components []Component `json:"components" yaml:"components" toml:"components"`
+	// Extends is the path to a parent definition, relative to the definition's file, providing the base components
+	Extends string `json:"extends" yaml:"extends,omitempty" toml:"extends,omitempty"`
+	// Include is the list of paths to definitions, relative to the definition's file, whose components are added
+	Include []string `json:"include" yaml:"include,omitempty" toml:"include,omitempty"`
+	// Remove is the list of components from the parent definition and the included definitions that are not part of the stack
+	Remove []string `json:"remove" yaml:"remove,omitempty" toml:"remove,omitempty"`
+	// Vars are user-defined variables that can be referenced with ${name} in the fields of the components
+	Vars map[string]string `json:"vars" yaml:"vars,omitempty" toml:"vars,omitempty"`
+	// EnvVars is the list of environment variables that can be referenced with ${env.NAME} in the fields of the components
+	EnvVars []string `json:"env_vars" yaml:"env_vars,omitempty" toml:"env_vars,omitempty"`
 }
 
 type Config struct {
@@ -51,208 +89,515 @@ type Config struct {
 	BuildEnv        []string
 	StackConfig     *StackCfg
 	StackDefinition *StackDef
+	// defFiles is the list of all the files the stack definition was loaded from
+	defFiles []string
+	// UpdateLock specifies whether the lock file is refreshed with the current state of the sources
+	// before installing the stack. Otherwise, the lock file, when it exists, is honored.
+	UpdateLock bool
+	// lock is the lock honored when installing the stack
+	lock *LockFile
+
+	// inputs is the input hash of every component, used to detect the components to rebuild
+	inputs map[string]string
+	// EnvMode specifies how the environment used to build the components is created
+	EnvMode buildenv.EnvMode
+	// EnvPassthrough is the list of environment variables passed to the builds in a hermetic environment
+	EnvPassthrough []string
+	// HermeticPath is the value of PATH in a hermetic environment, buildenv.DefaultHermeticPath when empty
+	HermeticPath string
+	// Concurrency is the maximum number of components installed at the same time, 0 meaning one at a time
+	Concurrency int
+	// KeepGoing specifies whether the installation reports all the components that failed to install,
+	// with a summary of the installation, instead of only the first one. Components whose dependencies
+	// succeeded are installed in both cases.
+	KeepGoing bool
+	// Cascade specifies whether removing a component also removes all the components depending on it
+	Cascade bool
+	// LockWait specifies whether to wait for other processes modifying the stack to complete instead of
+	// failing immediately
+	LockWait bool
+	// ComponentLocks specifies whether installing the stack only locks the components being installed,
+	// so several processes can install the same stack at the same time. Otherwise, the entire stack is
+	// locked for the duration of the installation, which is always the case with generations.
+	ComponentLocks bool
+	// Generations specifies whether each installation of the stack is done in a new generation, which
+	// only becomes the current generation once all the components are successfully installed
+	Generations bool
+	// generation is the generation being installed, 0 outside of an installation
+	generation int
+	// StoreDir is the directory of a store shared by several stacks, where every component is installed
+	// once per input hash and linked into the stacks using it. The store is not used when empty.
+	StoreDir string
 }
 
 const (
 	defaultPermission = 0775
 )
 
+// Load loads the definition and the configuration of the stack. The format of each file, JSON, YAML
+// or TOML, is based on its extension. The parent and included definitions are resolved so the
+// stack definition is the flattened list of all the components of the stack, where all the references
+// to variables are replaced with their values.
 func (c *Config) Load() error {
-	// unmarshale the two configuration files
-	defFile, err := os.Open(c.DefFilePath)
+	var err error
+	c.StackDefinition, c.defFiles, err = loadDefinition(c.DefFilePath, nil)
 	if err != nil {
-		return fmt.Errorf("unable to open %s: %w", c.DefFilePath, err)
+		return err
 	}
-	defContent, err := ioutil.ReadAll(defFile)
+
+	c.StackConfig = new(StackCfg)
+	err = decodeFile(c.ConfigFilePath, c.StackConfig)
 	if err != nil {
-		return fmt.Errorf("unable to read the content of %s: %w", c.DefFilePath, err)
+		return err
 	}
-	c.StackDefinition = new(StackDef)
-	err = json.Unmarshal(defContent, &c.StackDefinition)
+
+	err = c.interpolate()
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal content of %s: %w", c.DefFilePath, err)
+		return fmt.Errorf("unable to resolve the variables of %s: %w", c.DefFilePath, err)
 	}
+	c.loaded = true
+	return nil
+}
 
-	cfgFile, err := os.Open(c.ConfigFilePath)
-	if err != nil {
-		return fmt.Errorf("unable to open %s: %w", c.ConfigFilePath, err)
+// getTimeouts returns the timeouts of the different stages of the installation of the component
+func (comp *Component) getTimeouts() (map[builder.Stage]time.Duration, error) {
+	timeouts := make(map[builder.Stage]time.Duration)
+	for stageName, value := range comp.Timeouts {
+		stage := builder.Stage(stageName)
+		known := false
+		for _, s := range builder.Stages {
+			if s == stage {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("invalid timeout for %s: unknown stage %s", comp.Name, stageName)
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for the %s stage of %s: %w", stageName, comp.Name, err)
+		}
+		timeouts[stage] = timeout
 	}
-	cfgContent, err := ioutil.ReadAll(cfgFile)
+	return timeouts, nil
+}
+
+func (c *Config) getStackBasedir() string {
+	return filepath.Join(c.StackConfig.InstallDir, c.StackDefinition.Name)
+}
+
+func (c *Config) getComponentInstallDir(comp *Component) string {
+	return filepath.Join(c.getInstallRoot(), comp.getPath())
+}
+
+// id returns the identifier of a component in the stack, i.e., its name for a component without
+// version, name@version otherwise, so several versions of the same software can be in a stack
+func (comp *Component) id() string {
+	if comp.Version == "" {
+		return comp.Name
+	}
+	return comp.Name + "@" + comp.Version
+}
+
+// getPath returns the relative path of the component in the layout of the stack, e.g., in the install
+// directory or in the modulefiles: <name> for a component without version, <name>/<version> otherwise
+func (comp *Component) getPath() string {
+	if comp.Version == "" {
+		return comp.Name
+	}
+	return filepath.Join(comp.Name, comp.Version)
+}
+
+// errUnknownComponent is the error returned when a reference does not designate any component
+var errUnknownComponent = errors.New("not a component of the stack")
+
+// resolveComponent returns the component a reference designates. A reference is either the identifier
+// of a component or simply its name when the stack has a single version of the component.
+func (c *Config) resolveComponent(ref string) (*Component, error) {
+	var match *Component
+	count := 0
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		if comp.id() == ref {
+			return comp, nil
+		}
+		if comp.Name == ref {
+			match = comp
+			count++
+		}
+	}
+	switch count {
+	case 0:
+		return nil, fmt.Errorf("%s is %w", ref, errUnknownComponent)
+	case 1:
+		return match, nil
+	}
+	return nil, fmt.Errorf("the stack has several versions of %s, the version must be specified as %s@<version>", ref, ref)
+}
+
+func (c *Config) getComponent(ref string) *Component {
+	comp, err := c.resolveComponent(ref)
 	if err != nil {
-		return fmt.Errorf("unable to read the content of %s: %w", c.ConfigFilePath, err)
+		return nil
 	}
-	c.StackConfig = new(StackCfg)
-	err = json.Unmarshal(cfgContent, &c.StackConfig)
+	return comp
+}
+
+// getDependencies returns the name of the components a component directly depends on
+func (comp *Component) getDependencies() []string {
+	var deps []string
+	if comp.ConfigureDependency == "" {
+		return nil
+	}
+	for _, dep := range strings.Split(comp.ConfigureDependency, ",") {
+		dep = strings.TrimSpace(dep)
+		if dep != "" {
+			deps = append(deps, dep)
+		}
+	}
+	return deps
+}
+
+// getTransitiveDependencies returns the identifier of all the components a component depends on, direct
+// dependencies first
+func (c *Config) getTransitiveDependencies(comp *Component) ([]string, error) {
+	var result []string
+	visited := map[string]bool{comp.id(): true}
+	queue := comp.getDependencies()
+	for len(queue) > 0 {
+		depRef := queue[0]
+		queue = queue[1:]
+		dep, err := c.resolveComponent(depRef)
+		if err != nil {
+			return nil, fmt.Errorf("%s depends on %s: %w", comp.id(), depRef, err)
+		}
+		if visited[dep.id()] {
+			continue
+		}
+		visited[dep.id()] = true
+		result = append(result, dep.id())
+		queue = append(queue, dep.getDependencies()...)
+	}
+	return result, nil
+}
+
+// getDependencyEnv returns the environment variables giving a component access to the install
+// directories of all its dependencies, based on the same layout than the modulefiles. baseEnv is
+// the environment the variables are prepended to.
+func (c *Config) getDependencyEnv(comp *Component, baseEnv []string) ([]string, error) {
+	deps, err := c.getTransitiveDependencies(comp)
 	if err != nil {
-		return fmt.Errorf("unable to unmarshal content of %s: %w", c.ConfigFilePath, err)
+		return nil, err
 	}
-	c.loaded = true
-	return nil
+
+	var envVarNames []string
+	envLayout := make(map[string][]string)
+	var cppFlags, ldFlags []string
+	for _, depName := range deps {
+		depLayout := getInstallLayout(c.getDependencyInstallDir(c.getComponent(depName)))
+		for _, envVarName := range []string{"PATH", "LIBRARY_PATH", "LD_LIBRARY_PATH", "CPATH", "MANPATH", "PKG_CONFIG_PATH"} {
+			if len(depLayout[envVarName]) == 0 {
+				continue
+			}
+			if _, ok := envLayout[envVarName]; !ok {
+				envVarNames = append(envVarNames, envVarName)
+			}
+			envLayout[envVarName] = append(envLayout[envVarName], depLayout[envVarName]...)
+		}
+		for _, incDir := range depLayout["CPATH"] {
+			cppFlags = append(cppFlags, "-I"+incDir)
+		}
+		for _, libDir := range depLayout["LIBRARY_PATH"] {
+			ldFlags = append(ldFlags, "-L"+libDir)
+		}
+	}
+
+	baseValues := make(map[string]string)
+	for _, e := range baseEnv {
+		tokens := strings.SplitN(e, "=", 2)
+		if len(tokens) == 2 {
+			baseValues[tokens[0]] = tokens[1]
+		}
+	}
+
+	var depEnv []string
+	for _, envVarName := range envVarNames {
+		value := strings.Join(envLayout[envVarName], ":")
+		if baseValues[envVarName] != "" {
+			value += ":" + baseValues[envVarName]
+		}
+		depEnv = append(depEnv, envVarName+"="+value)
+	}
+	if len(cppFlags) > 0 {
+		depEnv = append(depEnv, "CPPFLAGS="+strings.TrimSpace(strings.Join(cppFlags, " ")+" "+baseValues["CPPFLAGS"]))
+	}
+	if len(ldFlags) > 0 {
+		depEnv = append(depEnv, "LDFLAGS="+strings.TrimSpace(strings.Join(ldFlags, " ")+" "+baseValues["LDFLAGS"]))
+	}
+	return depEnv, nil
 }
 
 func (c *Config) InstallStack() error {
-	// A map of all the installed components where the key of the component's name and the value the directory where it is installed
-	installedComponents := make(map[string]string)
+	return c.InstallStackContext(context.Background())
+}
 
-	// A map of all the identifiers used to configure the different components with dependencies
-	configIds := make(map[string]string)
+// InstallStackContext installs all the components of the stack. When the context is done, the
+// installation of the current components is interrupted and all the processes they started are killed.
+// Callers typically cancel the context when receiving SIGINT so a Ctrl-C stops the entire build.
+func (c *Config) InstallStackContext(ctx context.Context) error {
+	_, err := c.InstallStackWithReport(ctx)
+	return err
+}
 
+// InstallStackWithReport installs all the components of the stack and returns the outcome of the
+// installation of each component. The report is nil when the installation could not start.
+func (c *Config) InstallStackWithReport(ctx context.Context) (*InstallReport, error) {
 	if !c.loaded {
 		err := c.Load()
 		if err != nil {
-			return fmt.Errorf("unable to load configuration: %w", err)
+			return nil, fmt.Errorf("unable to load configuration: %w", err)
 		}
 	}
 
 	if c.StackDefinition.Type == "private" && c.Private {
-		return fmt.Errorf("you are trying to install a private stack on a public system, which is strictly prohibited! Please use the -private option if you are on a private system to deploy the target stack")
+		return nil, fmt.Errorf("you are trying to install a private stack on a public system, which is strictly prohibited! Please use the -private option if you are on a private system to deploy the target stack")
 	}
 
-	if !util.PathExists(c.StackConfig.InstallDir) {
-		err := os.MkdirAll(c.StackConfig.InstallDir, defaultPermission)
-		if err != nil {
-			return fmt.Errorf("unable to create installation directory %s: %w", c.StackConfig.InstallDir, err)
-		}
+	// Problems in the stack are reported before anything is created on the system
+	err := c.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack: %w", err)
 	}
 
-	for _, softwareComponents := range c.StackDefinition.Components {
-		// Set a builder
-		b := new(builder.Builder)
+	// Components are installed only after all their dependencies
+	graph, err := c.Graph()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack definition: %w", err)
+	}
+	installOrder, err := graph.TopologicalOrder()
+	if err != nil {
+		return nil, fmt.Errorf("invalid stack definition: %w", err)
+	}
 
-		stackBasedir := filepath.Join(c.StackConfig.InstallDir, c.StackDefinition.Name)
-		if !util.PathExists(stackBasedir) {
-			err := os.MkdirAll(stackBasedir, defaultPermission)
-			if err != nil {
-				return fmt.Errorf("unable to create %s: %w", stackBasedir, err)
-			}
-		}
-		b.Env.ScratchDir = filepath.Join(stackBasedir, "scratch")
-		b.Env.InstallDir = filepath.Join(stackBasedir, "install")
-		b.Env.BuildDir = filepath.Join(stackBasedir, "build")
-		b.Env.SrcDir = filepath.Join(stackBasedir, "src")
-		b.Env.Env = c.BuildEnv
-
-		if !util.PathExists(b.Env.ScratchDir) {
-			err := os.MkdirAll(b.Env.ScratchDir, defaultPermission)
-			if err != nil {
-				return fmt.Errorf("unable to create %s: %w", b.Env.ScratchDir, err)
-			}
-		}
+	// A generation is a complete installation of the stack, so installations into generations are
+	// never concurrent, even with component locks
+	stackLock, err := c.lockStack(ctx, c.ComponentLocks && !c.Generations)
+	if err != nil {
+		return nil, err
+	}
+	defer stackLock.Release()
+
+	// The lock file is shared by all the installations of the stack so it is only updated while
+	// holding the lock of the stack
+	if c.UpdateLock {
+		err = c.LockContext(ctx)
+	} else {
+		err = c.loadLock()
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		if !util.PathExists(b.Env.InstallDir) {
-			err := os.MkdirAll(b.Env.InstallDir, defaultPermission)
+	stackBasedir := c.getStackBasedir()
+	installDir := filepath.Join(stackBasedir, "install")
+	if c.Generations {
+		installDir = c.getGenerationsDir()
+	}
+	for _, dir := range []string{c.StackConfig.InstallDir, stackBasedir, filepath.Join(stackBasedir, "scratch"), installDir, filepath.Join(stackBasedir, "build"), filepath.Join(stackBasedir, "src"), c.getLogDir()} {
+		if !util.PathExists(dir) {
+			err := os.MkdirAll(dir, defaultPermission)
 			if err != nil {
-				return fmt.Errorf("unable to create %s: %w", b.Env.InstallDir, err)
+				return nil, fmt.Errorf("unable to create %s: %w", dir, err)
 			}
 		}
+	}
 
-		if !util.PathExists(b.Env.BuildDir) {
-			err := os.MkdirAll(b.Env.BuildDir, defaultPermission)
-			if err != nil {
-				return fmt.Errorf("unable to create %s: %w", b.Env.BuildDir, err)
-			}
+	c.inputs = c.inputHashes(ctx, graph, installOrder)
+	if c.Generations {
+		// Users keep using the current generation until the new one is complete
+		err = c.prepareGeneration()
+		defer func() {
+			c.generation = 0
+		}()
+		if err != nil {
+			return nil, err
 		}
-
-		if !util.PathExists(b.Env.SrcDir) {
-			err := os.MkdirAll(b.Env.SrcDir, defaultPermission)
-			if err != nil {
-				return fmt.Errorf("unable to create %s: %w", b.Env.SrcDir, err)
-			}
+	}
+	report := c.schedule(ctx, graph, installOrder)
+	if c.KeepGoing {
+		log.Printf("Installation summary:\n%s", report)
+	}
+	if c.Generations && report.Err() == nil {
+		err = c.activateGeneration()
+		if err != nil {
+			return report, err
 		}
+	}
+	// Only keeping going aggregates all the failures, otherwise the error is the one of the first
+	// component that failed
+	err = report.Err()
+	var installErr *InstallError
+	if !c.KeepGoing && errors.As(err, &installErr) {
+		err = &InstallError{Failures: installErr.Failures[:1]}
+	}
+	return report, err
+}
 
-		log.Printf("-> Installing %s", softwareComponents.Name)
-		b.App.Name = softwareComponents.Name
-		b.App.Source.URL = softwareComponents.URL
-		b.App.Source.Branch = softwareComponents.Branch
-
-		if softwareComponents.ConfigureDependency != "" {
-			deps := strings.Split(softwareComponents.ConfigureDependency, ",")
-			for _, dep := range deps {
-				ref := dep
-				_, ok := configIds[dep]
-				if ok {
-					ref = configIds[dep]
-				}
-				configureOption := fmt.Sprintf("--with-%s=%s", ref, installedComponents[dep])
-				b.App.AutotoolsCfg.ExtraConfigureArgs = append(b.App.AutotoolsCfg.ExtraConfigureArgs, configureOption)
-			}
-		}
+// newComponentBuilder returns the builder used to install a component of the stack. All the
+// dependencies of the component must already be installed.
+func (c *Config) newComponentBuilder(comp *Component) (*builder.Builder, error) {
+	b := new(builder.Builder)
 
-		if softwareComponents.ConfigureParams != "" {
-			args := strings.Split(softwareComponents.ConfigureParams, " ")
-			b.App.AutotoolsCfg.ExtraConfigureArgs = append(b.App.AutotoolsCfg.ExtraConfigureArgs, args...)
+	stackBasedir := c.getStackBasedir()
+	b.Env.ScratchDir = filepath.Join(stackBasedir, "scratch")
+	b.Env.InstallDir = c.getInstallRoot()
+	if c.useStore(comp) {
+		b.Env.InstallDir = c.getStoreEntryRoot(comp)
+	}
+	b.Env.BuildDir = filepath.Join(stackBasedir, "build")
+	b.Env.SrcDir = filepath.Join(stackBasedir, "src")
+	b.Env.Env = append([]string{}, c.BuildEnv...)
+	b.Env.Env = append(b.Env.Env, comp.Env...)
+	b.Env.MakeExtraArgs = comp.MakeExtraArgs
+	b.Env.EnvMode = c.EnvMode
+	b.Env.EnvPassthrough = c.EnvPassthrough
+	b.Env.HermeticPath = c.HermeticPath
+
+	// Give the component access to everything its dependencies installed
+	depEnv, err := c.getDependencyEnv(comp, b.Env.GetEffectiveEnv())
+	if err != nil {
+		return nil, err
+	}
+	b.Env.Env = append(b.Env.Env, depEnv...)
+
+	b.App.Name = comp.Name
+	b.App.Version = comp.Version
+	b.App.Source.URL = comp.URL
+	b.App.Source.Branch = comp.Branch
+	b.App.InstallCmd = comp.InstallCmd
+	if c.lock != nil {
+		if locked := c.lock.get(comp.id()); locked != nil {
+			b.App.Source.Commit = locked.Commit
+			b.App.Source.Digest = locked.Digest
 		}
-
-		if softwareComponents.ConfigurePrelude != "" {
-			b.App.AutotoolsCfg.ConfigurePreludeCmd = softwareComponents.ConfigurePrelude
+	}
+	b.BuildScript = comp.BuildScript
+	b.SudoRequired = comp.SudoRequired
+
+	for _, dep := range comp.getDependencies() {
+		depComp := c.getComponent(dep)
+		ref := depComp.Name
+		if depComp.ConfigId != "" {
+			ref = depComp.ConfigId
 		}
+		configureOption := fmt.Sprintf("--with-%s=%s", ref, c.getDependencyInstallDir(depComp))
+		b.App.AutotoolsCfg.ExtraConfigureArgs = append(b.App.AutotoolsCfg.ExtraConfigureArgs, configureOption)
+	}
 
-		if softwareComponents.BranchCheckoutPrelude != "" {
-			b.App.Source.BranchCheckoutPrelude = softwareComponents.BranchCheckoutPrelude
-		}
+	if comp.ConfigureParams != "" {
+		args := strings.Split(comp.ConfigureParams, " ")
+		b.App.AutotoolsCfg.ExtraConfigureArgs = append(b.App.AutotoolsCfg.ExtraConfigureArgs, args...)
+	}
 
-		err := b.Load(true)
-		if err != nil {
-			return fmt.Errorf("unable to load the builder for %s: %w", b.App.Name, err)
-		}
+	if comp.ConfigurePrelude != "" {
+		b.App.AutotoolsCfg.ConfigurePreludeCmd = comp.ConfigurePrelude
+	}
 
-		res := b.Install()
-		if res.Err != nil {
-			return fmt.Errorf("unable to install %s: %w", softwareComponents.Name, res.Err)
-		}
+	if comp.BranchCheckoutPrelude != "" {
+		b.App.Source.BranchCheckoutPrelude = comp.BranchCheckoutPrelude
+	}
 
-		installedComponents[softwareComponents.Name] = filepath.Join(b.Env.InstallDir, softwareComponents.Name)
-		if softwareComponents.ConfigId != "" {
-			configIds[softwareComponents.Name] = softwareComponents.ConfigId
-		}
-		log.Printf("-> %s was successfully installed in %s", softwareComponents.Name, b.Env.SrcDir)
+	timeouts, err := comp.getTimeouts()
+	if err != nil {
+		return nil, err
+	}
+	b.Timeouts = timeouts
+
+	err = b.Load(true)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the builder for %s: %w", b.App.Name, err)
 	}
+	return b, nil
+}
 
+// runTar executes tar from a directory
+func runTar(dir string, args ...string) error {
+	tarBin, err := exec.LookPath("tar")
+	if err != nil {
+		return fmt.Errorf("tar is not available: %w", err)
+	}
+	tarCmd := exec.Command(tarBin, args...)
+	tarCmd.Dir = dir
+	var stderr, stdout bytes.Buffer
+	tarCmd.Stderr = &stderr
+	tarCmd.Stdout = &stdout
+	err = tarCmd.Run()
+	if err != nil {
+		return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+	}
 	return nil
 }
 
+// Export creates a tarball of the installed components of the stack, with an install directory at
+// its root. Links are followed so the tarball includes everything the components need, e.g., the
+// components reused from another generation or installed in the store, and can be used on another
+// system.
 func (c *Config) Export() error {
-	err := c.Load()
-	if err != nil {
-		return fmt.Errorf("c.Load() failed: %w", err)
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("c.Load() failed: %w", err)
+		}
 	}
 
-	stackBasedir := filepath.Join(c.StackConfig.InstallDir, c.StackDefinition.Name)
+	stackBasedir := c.getStackBasedir()
 	if !util.PathExists(stackBasedir) {
 		return fmt.Errorf("%s does not exist", stackBasedir)
 	}
 
-	installDir := filepath.Join(stackBasedir, "install")
+	installDir := c.getInstallRoot()
 	if !util.PathExists(installDir) {
 		return fmt.Errorf("%s does not exist", installDir)
 	}
 
-	tarballFilename := c.StackDefinition.Name + ".tar.bz2"
-	tarBin, err := exec.LookPath("tar")
+	// The install directory is exported through a link so the tarball has the same layout whether
+	// the stack has generations or not
+	tmpDir, err := ioutil.TempDir(stackBasedir, ".export")
 	if err != nil {
-		return fmt.Errorf("tar is not available: %w", err)
+		return fmt.Errorf("unable to create a temporary directory for the export: %w", err)
 	}
-	tarCmd := exec.Command(tarBin, "-cjf", tarballFilename, "install")
-	tarCmd.Dir = stackBasedir
-	var stderr, stdout bytes.Buffer
-	tarCmd.Stderr = &stderr
-	tarCmd.Stdout = &stdout
-	err = tarCmd.Run()
+	defer os.RemoveAll(tmpDir)
+	err = os.Symlink(installDir, filepath.Join(tmpDir, "install"))
 	if err != nil {
-		return fmt.Errorf("command failed: %w - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+		return fmt.Errorf("unable to create link to %s: %w", installDir, err)
+	}
+
+	tarballPath := filepath.Join(stackBasedir, c.StackDefinition.Name+".tar.bz2")
+	err = runTar(tmpDir, "-chjf", tarballPath, "install")
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Stack successfully export: %s\n", filepath.Join(stackBasedir, tarballFilename))
+	fmt.Printf("Stack successfully export: %s\n", tarballPath)
 	return nil
 }
 
+// Import installs the components of a tarball created by Export. With generations, the content of
+// the tarball becomes a new generation, which is the current generation once completely imported.
 func (c *Config) Import(filePath string) error {
-	err := c.Load()
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("c.Load() failed: %w", err)
+		}
+	}
+	filePath, err := filepath.Abs(filePath)
 	if err != nil {
-		return fmt.Errorf("c.Load() failed: %w", err)
+		return fmt.Errorf("unable to get the absolute path of %s: %w", filePath, err)
 	}
 
-	stackBasedir := filepath.Join(c.StackConfig.InstallDir, c.StackDefinition.Name)
+	stackBasedir := c.getStackBasedir()
 	if !util.PathExists(stackBasedir) {
 		err := os.MkdirAll(stackBasedir, defaultPermission)
 		if err != nil {
@@ -260,31 +605,90 @@ func (c *Config) Import(filePath string) error {
 		}
 	}
 
-	tarBin, err := exec.LookPath("tar")
+	if !c.Generations {
+		err = runTar(stackBasedir, "-xjf", filePath)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stack successfully import in %s\n", stackBasedir)
+		return nil
+	}
+
+	stackLock, err := c.lockStack(context.Background(), false)
 	if err != nil {
-		return fmt.Errorf("ERROR: tar is not available: %w", err)
+		return err
 	}
-	tarCmd := exec.Command(tarBin, "-xjf", filePath)
-	tarCmd.Dir = stackBasedir
-	var stderr, stdout bytes.Buffer
-	tarCmd.Stderr = &stderr
-	tarCmd.Stdout = &stdout
-	err = tarCmd.Run()
+	defer stackLock.Release()
+
+	tmpDir, err := ioutil.TempDir(stackBasedir, ".import")
+	if err != nil {
+		return fmt.Errorf("unable to create a temporary directory for the import: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = runTar(tmpDir, "-xjf", filePath)
+	if err != nil {
+		return err
+	}
+	number, err := c.newGeneration(filepath.Join(tmpDir, "install"))
 	if err != nil {
-		return fmt.Errorf("command failed: %s - stdout: %s - stderr: %s", err, stdout.String(), stderr.String())
+		return err
+	}
+	c.generation = number
+	defer func() {
+		c.generation = 0
+	}()
+	err = c.activateGeneration()
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Stack successfully import in %s\n", stackBasedir)
+	fmt.Printf("Stack successfully import in %s\n", c.getGenerationDir(number))
 	return nil
 }
 
+// getInstallLayout returns, for each environment variable to prepend, the directories of an install
+// directory that must be added to it
+func getInstallLayout(installDir string) map[string][]string {
+	envLayout := make(map[string][]string)
+	binDir := filepath.Join(installDir, "bin")
+	libDir := filepath.Join(installDir, "lib")
+	incDir := filepath.Join(installDir, "include")
+	manDir := filepath.Join(installDir, "man")
+	pkgDir := filepath.Join(libDir, "pkgconfig")
+
+	if util.PathExists(binDir) {
+		envLayout["PATH"] = append(envLayout["PATH"], binDir)
+	}
+
+	if util.PathExists(libDir) {
+		envLayout["LIBRARY_PATH"] = append(envLayout["LIBRARY_PATH"], libDir)
+		envLayout["LD_LIBRARY_PATH"] = append(envLayout["LD_LIBRARY_PATH"], libDir)
+	}
+
+	if util.PathExists(incDir) {
+		envLayout["CPATH"] = append(envLayout["CPATH"], incDir)
+	}
+
+	if util.PathExists(manDir) {
+		envLayout["MANPATH"] = append(envLayout["MANPATH"], manDir)
+	}
+
+	if util.PathExists(pkgDir) {
+		envLayout["PKG_CONFIG_PATH"] = append(envLayout["PKG_CONFIG_PATH"], pkgDir)
+	}
+
+	return envLayout
+}
+
 func (c *Config) GenerateModules(copyright, customEnvVarPrefix string) error {
-	err := c.Load()
-	if err != nil {
-		return fmt.Errorf("c.Load() failed: %w", err)
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("c.Load() failed: %w", err)
+		}
 	}
 
-	stackBasedir := filepath.Join(c.StackConfig.InstallDir, c.StackDefinition.Name)
+	stackBasedir := c.getStackBasedir()
 	if !util.PathExists(stackBasedir) {
 		return fmt.Errorf("stack base directory %s does not exist", stackBasedir)
 	}
@@ -301,30 +705,27 @@ func (c *Config) GenerateModules(copyright, customEnvVarPrefix string) error {
 		var requires []string
 		vars := make(map[string]string)
 		envVars := make(map[string]string)
-		envLayout := make(map[string][]string)
 
-		// Set the requirements
-		if softwareComponent.ConfigureDependency != "" {
-			deps := strings.Split(softwareComponent.ConfigureDependency, ",")
-			requires = append(requires, deps...)
+		// Set the requirements, modulefiles of components with a version being named <name>/<version>
+		for _, dep := range softwareComponent.getDependencies() {
+			depComp, err := c.resolveComponent(dep)
+			if err != nil {
+				return fmt.Errorf("invalid dependency of %s: %w", softwareComponent.id(), err)
+			}
+			requires = append(requires, depComp.getPath())
 		}
 
 		// Set the vars
 		vars["software_stack_dir"] = stackBasedir
 
-		compInstallDir := filepath.Join(stackBasedir, "install", softwareComponent.Name)
-		compBinDir := filepath.Join(compInstallDir, "bin")
-		compLibDir := filepath.Join(compInstallDir, "lib")
-		compIncDir := filepath.Join(compInstallDir, "include")
-		compManDir := filepath.Join(compInstallDir, "man")
-		compPkgDir := filepath.Join(compLibDir, "pkgconfig")
+		compInstallDir := c.getComponentInstallDir(&softwareComponent)
 
 		// Set the new environment variables
 		compBasedirVarName := strings.ToUpper(softwareComponent.Name) + "_DIR"
 		compBasedirVarValue := compInstallDir
 		envVars[compBasedirVarName] = compBasedirVarValue
 
-		compBuildDir := filepath.Join(stackBasedir, "build", softwareComponent.Name)
+		compBuildDir := filepath.Join(stackBasedir, "build", softwareComponent.getPath())
 		if util.PathExists(compBuildDir) {
 			// Figure out the actual directory that was used
 			list, err := ioutil.ReadDir(compBuildDir)
@@ -368,28 +769,9 @@ func (c *Config) GenerateModules(copyright, customEnvVarPrefix string) error {
 		}
 
 		// Prepend existing environment variables
-		if util.PathExists(compBinDir) {
-			envLayout["PATH"] = append(envLayout["PATH"], compBinDir)
-		}
-
-		if util.PathExists(compLibDir) {
-			envLayout["LIBRARY_PATH"] = append(envLayout["LIBRARY_PATH"], compLibDir)
-			envLayout["LD_LIBRARY_PATH"] = append(envLayout["LD_LIBRARY_PATH"], compLibDir)
-		}
-
-		if util.PathExists(compIncDir) {
-			envLayout["CPATH"] = append(envLayout["CPATH"], compIncDir)
-		}
-
-		if util.PathExists(compManDir) {
-			envLayout["MANPATH"] = append(envLayout["MANPATH"], compManDir)
-		}
-
-		if util.PathExists(compPkgDir) {
-			envLayout["PKG_CONFIG_PATH"] = append(envLayout["PKG_CONFIG_PATH"], compPkgDir)
-		}
+		envLayout := getInstallLayout(compInstallDir)
 
-		err := module.Generate(modulefileDir, copyright, customEnvVarPrefix, softwareComponent.Name, requires, nil, vars, envVars, envLayout)
+		err := module.Generate(modulefileDir, copyright, customEnvVarPrefix, softwareComponent.getPath(), requires, nil, vars, envVars, envLayout)
 		if err != nil {
 			return fmt.Errorf("module.Generate() failed: %w", err)
 		}
@@ -0,0 +1,183 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestDependencyEnv(t *testing.T) {
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc"},
+			{Name: "pmix", ConfigureDependency: "hwloc"},
+			{Name: "ompi", ConfigureDependency: "pmix"},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+
+	for _, dir := range []string{"bin", "include", filepath.Join("lib", "pkgconfig")} {
+		for _, comp := range []string{"hwloc", "pmix"} {
+			path := filepath.Join(c.getComponentInstallDir(c.getComponent(comp)), dir)
+			err := os.MkdirAll(path, 0755)
+			if err != nil {
+				t.Fatalf("unable to create %s: %s", path, err)
+			}
+		}
+	}
+
+	depEnv, err := c.getDependencyEnv(c.getComponent("ompi"), []string{"PATH=/usr/bin", "LDFLAGS=-lm"})
+	if err != nil {
+		t.Fatalf("getDependencyEnv() failed: %s", err)
+	}
+	pmixDir := c.getComponentInstallDir(c.getComponent("pmix"))
+	hwlocDir := c.getComponentInstallDir(c.getComponent("hwloc"))
+	expected := []string{
+		"PATH=" + filepath.Join(pmixDir, "bin") + ":" + filepath.Join(hwlocDir, "bin") + ":/usr/bin",
+		"PKG_CONFIG_PATH=" + filepath.Join(pmixDir, "lib", "pkgconfig") + ":" + filepath.Join(hwlocDir, "lib", "pkgconfig"),
+		"CPPFLAGS=-I" + filepath.Join(pmixDir, "include") + " -I" + filepath.Join(hwlocDir, "include"),
+		"LDFLAGS=-L" + filepath.Join(pmixDir, "lib") + " -L" + filepath.Join(hwlocDir, "lib") + " -lm",
+	}
+	envStr := strings.Join(depEnv, "\n") + "\n"
+	for _, e := range expected {
+		if !strings.Contains(envStr, e+"\n") {
+			t.Fatalf("%s is missing from the dependency environment:\n%s", e, envStr)
+		}
+	}
+
+	// A component without dependency does not get any additional environment
+	depEnv, err = c.getDependencyEnv(c.getComponent("hwloc"), nil)
+	if err != nil {
+		t.Fatalf("getDependencyEnv() failed: %s", err)
+	}
+	if len(depEnv) != 0 {
+		t.Fatalf("unexpected dependency environment: %s", depEnv)
+	}
+}
+
+func TestComponentSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	customURL := createLocalComponent(t, dir, "custom", "")
+	files := map[string]string{
+		filepath.Join("custom", "install.sh"): "mkdir -p $DESTDIR$1/bin && cp helloworld $DESTDIR$1/bin/helloworld\n",
+		filepath.Join("scripts", "build.sh"):  "#!/bin/sh\ntest \"$GREETING\" = bonjour && printf '#!/bin/sh\\necho bonjour\\n' > helloworld && chmod +x helloworld\n",
+		"cfg.json":                            `{"installDir": "` + filepath.Join(dir, "install") + `"}`,
+		"def.json": `{
+	"name": "test",
+	"components": [
+		{"name": "custom", "URL": "` + customURL + `", "version": "1.0", "build_script": "scripts/build.sh", "install_cmd": "sh install.sh ${self.install_dir}", "env": ["GREETING=bonjour"]},
+		{"name": "std", "URL": "` + createLocalComponent(t, dir, "std", `test "$(GREETING)" = hola`) + `", "make_extra_args": ["GREETING=hola"]}
+	]
+}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", filepath.Dir(path), err)
+		}
+		err = ioutil.WriteFile(path, []byte(content), 0755)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", path, err)
+		}
+	}
+
+	c := &Config{DefFilePath: filepath.Join(dir, "def.json"), ConfigFilePath: filepath.Join(dir, "cfg.json")}
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	for _, name := range []string{"custom", "std"} {
+		expectedBinary := filepath.Join(c.getComponentInstallDir(c.getComponent(name)), "bin", "helloworld")
+		if !util.FileExists(expectedBinary) {
+			t.Fatalf("expected binary %s does not exist", expectedBinary)
+		}
+	}
+	content, err := ioutil.ReadFile(filepath.Join(c.getComponentInstallDir(c.getComponent("custom")), "bin", "helloworld"))
+	if err != nil || !strings.Contains(string(content), "bonjour") {
+		t.Fatalf("custom was not built with its build script: %s", content)
+	}
+}
+
+func TestSideBySideVersions(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	hwlocURL := createLocalComponent(t, srcDir, "hwloc", "")
+	def := &StackDef{
+		Name: "test",
+		Type: "public",
+		Components: []Component{
+			{Name: "hwloc", Version: "1.0", URL: hwlocURL},
+			{Name: "hwloc", Version: "2.0", URL: hwlocURL},
+			{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "hwloc"},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+
+	// A component cannot be defined both with and without a version since their directories overlap
+	def.Components = append(def.Components, Component{Name: "hwloc", URL: hwlocURL})
+	err = c.Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Diagnostics[0] != (Diagnostic{Component: "hwloc", Field: "version", Message: "hwloc is defined both with and without a version"}) {
+		t.Fatalf("mixing versioned and unversioned hwloc not detected: %v", err)
+	}
+	def.Components = def.Components[:3]
+
+	// The version of a dependency must be specified when the stack has several versions of it
+	err = c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "several versions of hwloc") {
+		t.Fatalf("ambiguous dependency not detected: %v", err)
+	}
+	def.Components[2].ConfigureDependency = "hwloc@2.0"
+
+	err = c.InstallStackContext(context.Background())
+	if err != nil {
+		t.Fatalf("unable to install the stack: %s", err)
+	}
+	installDir := filepath.Join(c.getStackBasedir(), "install")
+	for _, path := range []string{"hwloc/1.0", "hwloc/2.0", "ompi"} {
+		if !util.FileExists(filepath.Join(installDir, path, "bin", "helloworld")) {
+			t.Fatalf("%s is not installed", path)
+		}
+	}
+	ompiLog, err := ioutil.ReadFile(c.GetComponentLogPath("ompi"))
+	if err != nil || !strings.Contains(string(ompiLog), "--with-hwloc="+filepath.Join(installDir, "hwloc", "2.0")) {
+		t.Fatalf("ompi was not configured with hwloc 2.0 (%v)", err)
+	}
+
+	err = c.GenerateModules("", "")
+	if err != nil {
+		t.Fatalf("unable to generate the modulefiles: %s", err)
+	}
+	modulefile, err := ioutil.ReadFile(filepath.Join(c.getStackBasedir(), "modulefiles", "ompi"))
+	if err != nil || !strings.Contains(string(modulefile), "module load hwloc/2.0\n") {
+		t.Fatalf("the modulefile of ompi does not require hwloc 2.0 (%v): %s", err, modulefile)
+	}
+	if !util.FileExists(filepath.Join(c.getStackBasedir(), "modulefiles", "hwloc", "1.0")) {
+		t.Fatalf("the modulefile of hwloc 1.0 does not exist")
+	}
+}
@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// InstallState is the state of a component on the system
+type InstallState string
+
+const (
+	// StateInstalled means that the component is installed
+	StateInstalled InstallState = "installed"
+
+	// StateMissing means that the component was never installed or was removed
+	StateMissing InstallState = "missing"
+
+	// StateFailed means that the last installation of the component failed
+	StateFailed InstallState = "failed"
+)
+
+// ComponentState is the state of a component of a stack
+type ComponentState struct {
+	Name string `json:"name"`
+
+	State InstallState `json:"state"`
+
+	InstallDir string `json:"install_dir"`
+
+	// InstalledAt is when the component was installed, nil when unknown
+	InstalledAt *time.Time `json:"installed_at,omitempty"`
+
+	// Commit and Digest identify the revision of the source code that was installed or that failed
+	Commit string `json:"commit,omitempty"`
+	Digest string `json:"digest,omitempty"`
+
+	// FailedStage and Error describe the last installation when it failed
+	FailedStage builder.Stage `json:"failed_stage,omitempty"`
+	Error       string        `json:"error,omitempty"`
+
+	// DefinitionChanged specifies whether the definition of the component changed since it was installed
+	DefinitionChanged bool `json:"definition_changed"`
+
+	// NewerCommits is the number of commits of the branch, in the local mirror of the Git repository,
+	// that are not part of the installed revision
+	NewerCommits int `json:"newer_commits"`
+
+	// Outdated specifies whether the component is installed but does not match its definition or the latest commits
+	Outdated bool `json:"outdated"`
+
+	LogPath string `json:"log_path,omitempty"`
+}
+
+// StackStatus is the state of all the components of a stack, in the order of the stack definition
+type StackStatus struct {
+	Name       string           `json:"name"`
+	Components []ComponentState `json:"components"`
+}
+
+// JSON returns the status in the JSON format
+func (s *StackStatus) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "\t")
+}
+
+// countNewerCommits returns the number of commits of the branch, in a local mirror, that are not
+// part of a given commit. Nothing is fetched from the remote repository.
+func countNewerCommits(ctx context.Context, mirrorDir string, commit string, branch string) int {
+	upstream := "origin/HEAD"
+	if branch != "" {
+		upstream = "origin/" + branch
+	}
+	out, err := runGitCmd(ctx, mirrorDir, "rev-list", "--count", commit+".."+upstream)
+	if err != nil {
+		// The branch is not a branch, e.g., a tag, or the mirror is gone
+		return 0
+	}
+	count, err := strconv.Atoi(out)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+// Status returns the state of all the components of the stack
+func (c *Config) Status() (*StackStatus, error) {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return nil, fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+
+	status := &StackStatus{Name: c.StackDefinition.Name}
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		state := ComponentState{
+			Name:       comp.id(),
+			State:      StateMissing,
+			InstallDir: c.getComponentInstallDir(comp),
+		}
+		if util.FileExists(c.GetComponentLogPath(comp.id())) {
+			state.LogPath = c.GetComponentLogPath(comp.id())
+		}
+		record, err := c.loadRecord(comp.id())
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case util.PathExists(state.InstallDir):
+			state.State = StateInstalled
+			if record == nil || record.Status != StatusSucceeded {
+				// Installed by something else than the stack, nothing else is known
+				break
+			}
+			installedAt := record.Time
+			state.InstalledAt = &installedAt
+			state.Commit = record.Commit
+			state.Digest = record.Digest
+			state.DefinitionChanged = record.DefinitionHash != comp.definitionHash()
+			if record.Commit != "" && util.IsDir(record.SrcDir) {
+				state.NewerCommits = countNewerCommits(context.Background(), record.SrcDir, record.Commit, comp.Branch)
+			}
+			state.Outdated = state.DefinitionChanged || state.NewerCommits > 0
+		case record != nil && record.Status == StatusFailed:
+			state.State = StateFailed
+			state.Commit = record.Commit
+			state.Digest = record.Digest
+			state.FailedStage = record.Stage
+			state.Error = record.Error
+		}
+		status.Components = append(status.Components, state)
+	}
+	return status, nil
+}
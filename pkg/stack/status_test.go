@@ -0,0 +1,75 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+)
+
+func TestStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	repoPath, workDir, branch := createLocalGitComponent(t, dir)
+	installedCommit := runGit(t, workDir, "rev-parse", "HEAD")
+
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hello", URL: repoPath, Branch: branch},
+			{Name: "broken", URL: createLocalComponent(t, dir, "broken", "exit 1")},
+			{Name: "never", URL: createLocalComponent(t, dir, "never", ""), ConfigureDependency: "broken"},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+	c.KeepGoing = true
+	err = c.InstallStackContext(context.Background())
+	if err == nil {
+		t.Fatalf("installation succeeded while broken fails")
+	}
+
+	checkStatus := func(expected []ComponentState) {
+		status, err := c.Status()
+		if err != nil {
+			t.Fatalf("unable to get the status of the stack: %s", err)
+		}
+		for idx, e := range expected {
+			s := status.Components[idx]
+			if s.Name != e.Name || s.State != e.State || s.Commit != e.Commit || s.FailedStage != e.FailedStage || s.DefinitionChanged != e.DefinitionChanged || s.NewerCommits != e.NewerCommits || s.Outdated != e.Outdated {
+				t.Fatalf("status of %s is %+v instead of %+v", e.Name, s, e)
+			}
+		}
+		content, err := status.JSON()
+		if err != nil || !strings.Contains(string(content), `"state": "installed"`) {
+			t.Fatalf("invalid JSON status (%v): %s", err, content)
+		}
+	}
+
+	checkStatus([]ComponentState{
+		{Name: "hello", State: StateInstalled, Commit: installedCommit},
+		{Name: "broken", State: StateFailed, FailedStage: builder.StageBuild},
+		{Name: "never", State: StateMissing},
+	})
+
+	// Changes in the definition and new commits in the local mirror make the component outdated
+	pushLocalGitCommit(t, workDir, repoPath, branch)
+	runGit(t, filepath.Join(c.getStackBasedir(), "build", "hello", "hello"), "fetch", "-q")
+	def.Components[0].ConfigureParams = "--enable-debug"
+	checkStatus([]ComponentState{
+		{Name: "hello", State: StateInstalled, Commit: installedCommit, DefinitionChanged: true, NewerCommits: 1, Outdated: true},
+	})
+}
@@ -0,0 +1,316 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/lock"
+	"github.com/BTMichalowicz/go_software_build/internal/pkg/process"
+	"github.com/BTMichalowicz/go_software_build/pkg/builder"
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+const (
+	// storeLockFileName is the name of the file, at the root of the store, locked while the store is
+	// used. Installations hold a shared lock while the garbage collection holds an exclusive lock.
+	storeLockFileName = ".store.lock"
+
+	// storeEntryLockDirName is the name of the directory, at the root of the store, with the files
+	// locked while entries are installed
+	storeEntryLockDirName = ".locks"
+
+	// storeReferenceDirName is the name of the directory of an entry of the store where every stack
+	// using the entry is referenced
+	storeReferenceDirName = ".stacks"
+)
+
+// useStore returns whether a component is installed in the store, which requires the input hash of the
+// component
+func (c *Config) useStore(comp *Component) bool {
+	return c.StoreDir != "" && c.inputs[comp.id()] != ""
+}
+
+// getStoreEntryRoot returns the directory of the store where a component is installed, based on its
+// input hash, so identical builds are shared by all the stacks
+func (c *Config) getStoreEntryRoot(comp *Component) string {
+	return filepath.Join(c.StoreDir, c.inputs[comp.id()])
+}
+
+func (c *Config) getStoreEntryDir(comp *Component) string {
+	return filepath.Join(c.getStoreEntryRoot(comp), comp.getPath())
+}
+
+// getDependencyInstallDir returns the directory where a dependency is installed, as seen by the
+// components depending on it. With a store, it is the entry of the store and not the link from the
+// stack so entries never depend on the stack that built them.
+func (c *Config) getDependencyInstallDir(comp *Component) string {
+	if c.useStore(comp) {
+		return c.getStoreEntryDir(comp)
+	}
+	return c.getComponentInstallDir(comp)
+}
+
+// lockStore acquires the lock of the store
+func lockStore(ctx context.Context, storeDir string, opts lock.Options) (*lock.FileLock, error) {
+	err := os.MkdirAll(storeDir, defaultPermission)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", storeDir, err)
+	}
+	l, err := lock.AcquireContext(ctx, filepath.Join(storeDir, storeLockFileName), opts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock store %s: %w", storeDir, err)
+	}
+	return l, nil
+}
+
+// lockStoreEntry acquires the lock of an entry of the store. Stacks installing the same entry at the
+// same time wait for each other so the entry is only built once.
+func (c *Config) lockStoreEntry(ctx context.Context, hash string) (*lock.FileLock, error) {
+	lockDir := filepath.Join(c.StoreDir, storeEntryLockDirName)
+	err := os.MkdirAll(lockDir, defaultPermission)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", lockDir, err)
+	}
+	l, err := lock.AcquireContext(ctx, filepath.Join(lockDir, hash+".lock"), lock.Options{Wait: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock store entry %s: %w", hash, err)
+	}
+	return l, nil
+}
+
+// addStoreReference records that the stack uses an entry of the store
+func (c *Config) addStoreReference(comp *Component) error {
+	refDir := filepath.Join(c.getStoreEntryRoot(comp), storeReferenceDirName)
+	err := os.MkdirAll(refDir, defaultPermission)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", refDir, err)
+	}
+	stackBasedir := c.getStackBasedir()
+	h := sha256.Sum256([]byte(stackBasedir))
+	path := filepath.Join(refDir, hex.EncodeToString(h[:]))
+	err = ioutil.WriteFile(path, []byte(stackBasedir+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// linkStoreEntry makes the install directory of a component in the stack a link to its entry in the
+// store, replacing whatever was installed there before
+func (c *Config) linkStoreEntry(comp *Component) error {
+	err := c.addStoreReference(comp)
+	if err != nil {
+		return err
+	}
+
+	installDir := c.getComponentInstallDir(comp)
+	entryDir := c.getStoreEntryDir(comp)
+	err = os.MkdirAll(filepath.Dir(installDir), defaultPermission)
+	if err != nil {
+		return fmt.Errorf("unable to create %s: %w", filepath.Dir(installDir), err)
+	}
+	tmpLink := installDir + ".tmp"
+	err = os.RemoveAll(tmpLink)
+	if err != nil {
+		return fmt.Errorf("unable to remove %s: %w", tmpLink, err)
+	}
+	err = os.Symlink(entryDir, tmpLink)
+	if err != nil {
+		return fmt.Errorf("unable to create link to %s: %w", entryDir, err)
+	}
+	// A link is replaced atomically but a directory installed before using the store must be removed first
+	if info, err := os.Lstat(installDir); err == nil && info.IsDir() {
+		err := os.RemoveAll(installDir)
+		if err != nil {
+			return fmt.Errorf("unable to remove %s: %w", installDir, err)
+		}
+	}
+	err = os.Rename(tmpLink, installDir)
+	if err != nil {
+		os.Remove(tmpLink)
+		return fmt.Errorf("unable to move %s to %s: %w", tmpLink, installDir, err)
+	}
+	return nil
+}
+
+// installInStore installs a component in the store, unless an identical build is already there, and
+// links it into the stack
+func (c *Config) installInStore(ctx context.Context, comp *Component, b *builder.Builder, record *componentRecord) error {
+	name := comp.id()
+	installDir := c.getComponentInstallDir(comp)
+	entryDir := c.getStoreEntryDir(comp)
+	if target, err := os.Readlink(installDir); err == nil && target == entryDir && util.IsDir(entryDir) {
+		process.Logf(ctx, "-> %s is already installed from %s, skipping...", name, entryDir)
+		return nil
+	}
+
+	storeLock, err := lockStore(ctx, c.StoreDir, lock.Options{Shared: true, Wait: true})
+	if err != nil {
+		process.Logf(ctx, "%s", err)
+		return err
+	}
+	defer storeLock.Release()
+	entryLock, err := c.lockStoreEntry(ctx, c.inputs[name])
+	if err != nil {
+		process.Logf(ctx, "%s", err)
+		return err
+	}
+	defer entryLock.Release()
+
+	var installErr error
+	if util.IsDir(entryDir) {
+		process.Logf(ctx, "-> %s is already in the store (%s), reusing it", name, entryDir)
+		b = nil
+	} else {
+		if record != nil && record.InputHash != "" && record.InputHash != c.inputs[name] {
+			err = b.RestartFrom(builder.StageFetch)
+			if err != nil {
+				return fmt.Errorf("unable to restart the installation of %s: %w", name, err)
+			}
+		}
+		installErr = b.InstallContext(ctx).Err
+	}
+	if installErr == nil {
+		installErr = c.linkStoreEntry(comp)
+	}
+
+	// The record of a component that was already installed describes the installation in place, which
+	// is still the previous one when the installation failed
+	if installErr == nil || !util.PathExists(installDir) {
+		err = c.saveRecord(name, c.newRecord(ctx, comp, b, installErr))
+		if err != nil {
+			process.Logf(ctx, "unable to save the record of %s: %s", name, err)
+		}
+	}
+	if installErr != nil {
+		process.Logf(ctx, "unable to install %s: %s", name, installErr)
+		return installErr
+	}
+	return nil
+}
+
+// stackLinks returns the target of all the links from the install directories of a stack, i.e., the
+// install directory and all the generations
+func stackLinks(stackBasedir string) ([]string, error) {
+	dirs := []string{filepath.Join(stackBasedir, "install")}
+	generationsDir := filepath.Join(stackBasedir, generationsDirName)
+	if util.IsDir(generationsDir) {
+		entries, err := ioutil.ReadDir(generationsDir)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", generationsDir, err)
+		}
+		for _, entry := range entries {
+			if _, err := strconv.Atoi(entry.Name()); err == nil && entry.IsDir() {
+				dirs = append(dirs, filepath.Join(generationsDir, entry.Name()))
+			}
+		}
+	}
+
+	var targets []string
+	for _, dir := range dirs {
+		if !util.IsDir(dir) {
+			continue
+		}
+		links, err := componentLinks(dir)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, links...)
+	}
+	return targets, nil
+}
+
+// CollectStore removes the entries of a store that are no longer used by any stack. The stacks
+// referenced by an entry are checked so references from stacks that were removed, or that no longer
+// link to the entry, are dropped. The hash of the removed entries is returned.
+func CollectStore(storeDir string) ([]string, error) {
+	storeLock, err := lockStore(context.Background(), storeDir, lock.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer storeLock.Release()
+
+	entries, err := ioutil.ReadDir(storeDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", storeDir, err)
+	}
+	links := make(map[string][]string)
+	var removed []string
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		entryRoot := filepath.Join(storeDir, entry.Name())
+		refDir := filepath.Join(entryRoot, storeReferenceDirName)
+		var refs []os.FileInfo
+		if util.IsDir(refDir) {
+			refs, err = ioutil.ReadDir(refDir)
+			if err != nil {
+				return removed, fmt.Errorf("unable to read %s: %w", refDir, err)
+			}
+		}
+
+		used := false
+		for _, ref := range refs {
+			refPath := filepath.Join(refDir, ref.Name())
+			content, err := ioutil.ReadFile(refPath)
+			if err != nil {
+				return removed, fmt.Errorf("unable to read %s: %w", refPath, err)
+			}
+			stackBasedir := strings.TrimSpace(string(content))
+			if _, ok := links[stackBasedir]; !ok {
+				links[stackBasedir], err = stackLinks(stackBasedir)
+				if err != nil {
+					return removed, err
+				}
+			}
+			stackUsesEntry := false
+			for _, target := range links[stackBasedir] {
+				if strings.HasPrefix(target, entryRoot+string(filepath.Separator)) {
+					stackUsesEntry = true
+					break
+				}
+			}
+			if !stackUsesEntry {
+				err := os.Remove(refPath)
+				if err != nil {
+					return removed, fmt.Errorf("unable to remove %s: %w", refPath, err)
+				}
+				continue
+			}
+			used = true
+		}
+		if used {
+			continue
+		}
+
+		log.Printf("Removing %s from the store...", entry.Name())
+		err := os.RemoveAll(entryRoot)
+		if err != nil {
+			return removed, fmt.Errorf("unable to remove %s: %w", entryRoot, err)
+		}
+		lockPath := filepath.Join(storeDir, storeEntryLockDirName, entry.Name()+".lock")
+		if util.FileExists(lockPath) {
+			err := os.Remove(lockPath)
+			if err != nil {
+				log.Printf("unable to remove %s: %s", lockPath, err)
+			}
+		}
+		removed = append(removed, entry.Name())
+	}
+	return removed, nil
+}
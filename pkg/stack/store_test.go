@@ -0,0 +1,110 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+func TestStore(t *testing.T) {
+	srcDir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(srcDir)
+	storeDir := filepath.Join(srcDir, "store")
+
+	components := []Component{
+		{Name: "hwloc", URL: createLocalComponent(t, srcDir, "hwloc", "")},
+		{Name: "ompi", URL: createLocalComponent(t, srcDir, "ompi", ""), ConfigureDependency: "hwloc"},
+	}
+	var stacks []*Config
+	for _, name := range []string{"stack1", "stack2"} {
+		def := &StackDef{Name: name, Components: append([]Component{}, components...)}
+		c, cleanupFn := newTestConfig(t, def)
+		defer cleanupFn()
+		c.StoreDir = storeDir
+		err := c.InstallStackContext(context.Background())
+		if err != nil {
+			t.Fatalf("unable to install %s: %s", name, err)
+		}
+		stacks = append(stacks, c)
+	}
+
+	// Both stacks link to the same entries of the store, which are built only once
+	for _, name := range []string{"hwloc", "ompi"} {
+		entryDir := stacks[0].getStoreEntryDir(stacks[0].getComponent(name))
+		for _, c := range stacks {
+			target, err := os.Readlink(c.getComponentInstallDir(c.getComponent(name)))
+			if err != nil || target != entryDir {
+				t.Fatalf("%s of %s does not link to %s: %s (%v)", name, c.StackDefinition.Name, entryDir, target, err)
+			}
+		}
+		if !util.FileExists(filepath.Join(entryDir, "bin", "helloworld")) {
+			t.Fatalf("%s is not installed in the store", name)
+		}
+	}
+	ompiLog, err := ioutil.ReadFile(stacks[1].GetComponentLogPath("ompi"))
+	if err != nil || !strings.Contains(string(ompiLog), "already in the store") {
+		t.Fatalf("ompi was built again for the second stack (%v)", err)
+	}
+	ompiLog, err = ioutil.ReadFile(stacks[0].GetComponentLogPath("ompi"))
+	if err != nil || !strings.Contains(string(ompiLog), "--with-hwloc="+stacks[0].getStoreEntryDir(stacks[0].getComponent("hwloc"))) {
+		t.Fatalf("ompi was not configured with hwloc from the store (%v)", err)
+	}
+
+	// An entry is collected only once no stack uses it anymore
+	ompiEntry := stacks[0].getStoreEntryRoot(stacks[0].getComponent("ompi"))
+	err = stacks[0].Remove("ompi")
+	if err != nil {
+		t.Fatalf("unable to remove ompi: %s", err)
+	}
+	removed, err := CollectStore(storeDir)
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("entries used by the second stack were collected (%v): %v", err, removed)
+	}
+	err = os.RemoveAll(stacks[1].getStackBasedir())
+	if err != nil {
+		t.Fatalf("unable to remove the second stack: %s", err)
+	}
+	removed, err = CollectStore(storeDir)
+	if err != nil || len(removed) != 1 || removed[0] != filepath.Base(ompiEntry) {
+		t.Fatalf("removed %v instead of the entry of ompi (%v)", removed, err)
+	}
+	if !util.FileExists(filepath.Join(stacks[0].getComponentInstallDir(stacks[0].getComponent("hwloc")), "bin", "helloworld")) {
+		t.Fatalf("hwloc is no longer available for the first stack")
+	}
+
+	// The exported stack includes the content of the store, not links to it
+	if _, err := exec.LookPath("bzip2"); err == nil {
+		err = stacks[0].Export()
+		if err != nil {
+			t.Fatalf("unable to export the stack: %s", err)
+		}
+		extractDir := filepath.Join(srcDir, "extract")
+		err = os.MkdirAll(extractDir, 0755)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", extractDir, err)
+		}
+		err = runTar(extractDir, "-xjf", filepath.Join(stacks[0].getStackBasedir(), "stack1.tar.bz2"))
+		if err != nil {
+			t.Fatalf("unable to extract the exported stack: %s", err)
+		}
+		info, err := os.Lstat(filepath.Join(extractDir, "install", "hwloc", "bin", "helloworld"))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Fatalf("hwloc from the store was not exported (%v)", err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// Diagnostic is a problem found in the definition or the configuration of a stack
+type Diagnostic struct {
+	// Component is the name of the component with the problem, empty when the problem is not specific to a component
+	Component string
+
+	// Field is the name of the field with the problem, as written in the files
+	Field string
+
+	// Message describes the problem
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	var elts []string
+	if d.Component != "" {
+		elts = append(elts, "component "+d.Component)
+	}
+	if d.Field != "" {
+		elts = append(elts, d.Field)
+	}
+	elts = append(elts, d.Message)
+	return strings.Join(elts, ": ")
+}
+
+// ValidationError is the error returned when the definition or the configuration of a stack is invalid
+type ValidationError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	var msgs []string
+	for _, d := range e.Diagnostics {
+		msgs = append(msgs, d.String())
+	}
+	return fmt.Sprintf("%d problem(s) found: %s", len(e.Diagnostics), strings.Join(msgs, "; "))
+}
+
+var validStackTypes = []string{"", "public", "private"}
+
+// knownFields returns the keys accepted when decoding a structure from a given format
+func knownFields(v interface{}, format fileFormat) []string {
+	var fields []string
+	t := reflect.TypeOf(v)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get(string(format)), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// unknownFields returns the keys of an object that do not match any field of a structure. Like when
+// decoding, keys are matched without case sensitivity unless the format is case sensitive.
+func unknownFields(obj map[string]interface{}, v interface{}, format fileFormat) []string {
+	var unknown []string
+	known := knownFields(v, format)
+	for key := range obj {
+		found := false
+		for _, k := range known {
+			if key == k || (!format.caseSensitive() && strings.EqualFold(key, k)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func checkUnknownFields(obj map[string]interface{}, v interface{}, format fileFormat, component string) []Diagnostic {
+	var diags []Diagnostic
+	for _, field := range unknownFields(obj, v, format) {
+		diags = append(diags, Diagnostic{Component: component, Field: field, Message: "unknown field"})
+	}
+	return diags
+}
+
+// getObjects returns the objects of a list decoded without type information
+func getObjects(value interface{}) []map[string]interface{} {
+	switch list := value.(type) {
+	case []map[string]interface{}:
+		return list
+	case []interface{}:
+		var objs []map[string]interface{}
+		for _, elt := range list {
+			obj, _ := elt.(map[string]interface{})
+			objs = append(objs, obj)
+		}
+		return objs
+	}
+	return nil
+}
+
+// checkDefinitionFile checks that a definition file does not include unknown fields
+func checkDefinitionFile(path string) ([]Diagnostic, error) {
+	format := getFileFormat(path)
+	obj := make(map[string]interface{})
+	err := decodeFile(path, &obj)
+	if err != nil {
+		return nil, err
+	}
+	diags := checkUnknownFields(obj, StackDef{}, format, "")
+
+	for key, value := range obj {
+		if key != "components" && (format.caseSensitive() || !strings.EqualFold(key, "components")) {
+			continue
+		}
+		for idx, compObj := range getObjects(value) {
+			name, _ := compObj["name"].(string)
+			if name == "" {
+				name = fmt.Sprintf("#%d", idx+1)
+			}
+			diags = append(diags, checkUnknownFields(compObj, Component{}, format, name)...)
+		}
+	}
+	return diags, nil
+}
+
+// checkConfigFile checks that a configuration file does not include unknown fields
+func checkConfigFile(path string) ([]Diagnostic, error) {
+	obj := make(map[string]interface{})
+	err := decodeFile(path, &obj)
+	if err != nil {
+		return nil, err
+	}
+	return checkUnknownFields(obj, StackCfg{}, getFileFormat(path), ""), nil
+}
+
+func (comp *Component) validate(c *Config, index int) []Diagnostic {
+	var diags []Diagnostic
+	name := comp.id()
+	if comp.Name == "" {
+		name = fmt.Sprintf("#%d", index+1)
+		diags = append(diags, Diagnostic{Component: name, Field: "name", Message: "undefined name"})
+	}
+	if comp.URL == "" {
+		diags = append(diags, Diagnostic{Component: name, Field: "URL", Message: "undefined URL"})
+	}
+	for _, dep := range comp.getDependencies() {
+		depComp, err := c.resolveComponent(dep)
+		switch {
+		case errors.Is(err, errUnknownComponent):
+			diags = append(diags, Diagnostic{Component: name, Field: "configure_dependency", Message: fmt.Sprintf("unknown component %s", dep)})
+		case err != nil:
+			diags = append(diags, Diagnostic{Component: name, Field: "configure_dependency", Message: err.Error()})
+		case depComp == comp:
+			diags = append(diags, Diagnostic{Component: name, Field: "configure_dependency", Message: "the component depends on itself"})
+		}
+	}
+	if comp.BuildScript != "" && !util.FileExists(comp.BuildScript) {
+		diags = append(diags, Diagnostic{Component: name, Field: "build_script", Message: fmt.Sprintf("%s does not exist", comp.BuildScript)})
+	}
+	for _, e := range comp.Env {
+		if !strings.Contains(e, "=") {
+			diags = append(diags, Diagnostic{Component: name, Field: "env", Message: fmt.Sprintf("%s does not have the NAME=value format", e)})
+		}
+	}
+	_, err := comp.getTimeouts()
+	if err != nil {
+		diags = append(diags, Diagnostic{Component: name, Field: "timeouts", Message: err.Error()})
+	}
+	return diags
+}
+
+// Validate checks the definition and the configuration of the stack, including unknown fields in the
+// files they are loaded from. All the problems that are found are returned as a ValidationError.
+// It does not create or modify anything on the system.
+func (c *Config) Validate() error {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+
+	var diags []Diagnostic
+	defFiles := c.defFiles
+	if len(defFiles) == 0 && c.DefFilePath != "" {
+		defFiles = []string{c.DefFilePath}
+	}
+	for _, path := range defFiles {
+		fileDiags, err := checkDefinitionFile(path)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, fileDiags...)
+	}
+	if c.ConfigFilePath != "" {
+		fileDiags, err := checkConfigFile(c.ConfigFilePath)
+		if err != nil {
+			return err
+		}
+		diags = append(diags, fileDiags...)
+	}
+
+	if c.StackConfig.InstallDir == "" {
+		diags = append(diags, Diagnostic{Field: "installDir", Message: "undefined installation directory"})
+	}
+	if c.StackDefinition.Name == "" {
+		diags = append(diags, Diagnostic{Field: "name", Message: "undefined stack name"})
+	}
+	validType := false
+	for _, t := range validStackTypes {
+		if c.StackDefinition.Type == t {
+			validType = true
+			break
+		}
+	}
+	if !validType {
+		diags = append(diags, Diagnostic{Field: "type", Message: fmt.Sprintf("unknown type %s, valid types are: public, private", c.StackDefinition.Type)})
+	}
+
+	names := make(map[string]bool)
+	// A component without version is installed in the directory containing the directories of its
+	// versions so a name is either always or never used with a version
+	versioned := make(map[string]bool)
+	unversioned := make(map[string]bool)
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		if comp.Name != "" && names[comp.id()] {
+			diags = append(diags, Diagnostic{Component: comp.id(), Field: "name", Message: "the component is defined more than once"})
+		}
+		if comp.Name != "" && ((comp.Version == "" && versioned[comp.Name]) || (comp.Version != "" && unversioned[comp.Name])) {
+			diags = append(diags, Diagnostic{Component: comp.id(), Field: "version", Message: fmt.Sprintf("%s is defined both with and without a version", comp.Name)})
+		}
+		names[comp.id()] = true
+		if comp.Version == "" {
+			unversioned[comp.Name] = true
+		} else {
+			versioned[comp.Name] = true
+		}
+		diags = append(diags, comp.validate(c, idx)...)
+	}
+
+	// Cycles can only be reliably detected once all the dependencies are known to be valid
+	if len(diags) == 0 {
+		graph, err := c.Graph()
+		if err == nil {
+			_, err = graph.TopologicalOrder()
+		}
+		if err != nil {
+			diags = append(diags, Diagnostic{Field: "configure_dependency", Message: err.Error()})
+		}
+	}
+
+	if len(diags) > 0 {
+		return &ValidationError{Diagnostics: diags}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name                string
+		def                 string
+		cfg                 string
+		expectedDiagnostics []Diagnostic
+	}{
+		{
+			name: "valid",
+			def:  `{"name": "test", "type": "public", "components": [{"name": "hwloc", "URL": "file:///hwloc"}, {"name": "ompi", "URL": "file:///ompi", "configure_dependency": "hwloc", "timeouts": {"build": "1h"}}]}`,
+			cfg:  `{"installDir": "/tmp/test"}`,
+		},
+		{
+			name: "invalid",
+			def:  `{"name": "test", "type": "secret", "components": [{"name": "hwloc", "URL": "file:///hwloc", "configure_param": "--enable-debug"}, {"name": "hwloc", "URL": "file:///hwloc"}, {"name": "ompi", "configure_dependency": "pmix", "timeouts": {"compile": "1h"}}]}`,
+			cfg:  `{"installdir": "/tmp/test", "buildDir": "/tmp/build"}`,
+			expectedDiagnostics: []Diagnostic{
+				{Component: "hwloc", Field: "configure_param", Message: "unknown field"},
+				{Field: "buildDir", Message: "unknown field"},
+				{Field: "type", Message: "unknown type secret, valid types are: public, private"},
+				{Component: "hwloc", Field: "name", Message: "the component is defined more than once"},
+				{Component: "ompi", Field: "URL", Message: "undefined URL"},
+				{Component: "ompi", Field: "configure_dependency", Message: "unknown component pmix"},
+				{Component: "ompi", Field: "timeouts", Message: "invalid timeout for ompi: unknown stage compile"},
+			},
+		},
+		{
+			name: "cycle",
+			def:  `{"name": "test", "components": [{"name": "a", "URL": "file:///a", "configure_dependency": "b"}, {"name": "b", "URL": "file:///b", "configure_dependency": "a"}]}`,
+			cfg:  `{"installDir": "/tmp/test"}`,
+			expectedDiagnostics: []Diagnostic{
+				{Field: "configure_dependency", Message: "dependency cycle detected: a -> b -> a"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := new(Config)
+		c.DefFilePath = filepath.Join(dir, tt.name+"_def.json")
+		c.ConfigFilePath = filepath.Join(dir, tt.name+"_cfg.json")
+		err := ioutil.WriteFile(c.DefFilePath, []byte(tt.def), 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", c.DefFilePath, err)
+		}
+		err = ioutil.WriteFile(c.ConfigFilePath, []byte(tt.cfg), 0644)
+		if err != nil {
+			t.Fatalf("unable to create %s: %s", c.ConfigFilePath, err)
+		}
+
+		err = c.Validate()
+		if len(tt.expectedDiagnostics) == 0 {
+			if err != nil {
+				t.Fatalf("%s: validation failed: %s", tt.name, err)
+			}
+			continue
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if len(validationErr.Diagnostics) != len(tt.expectedDiagnostics) {
+			t.Fatalf("%s: got %d diagnostics instead of %d: %s", tt.name, len(validationErr.Diagnostics), len(tt.expectedDiagnostics), err)
+		}
+		for idx, d := range tt.expectedDiagnostics {
+			if validationErr.Diagnostics[idx] != d {
+				t.Fatalf("%s: diagnostic #%d is %+v instead of %+v", tt.name, idx, validationErr.Diagnostics[idx], d)
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BTMichalowicz/go_util/pkg/util"
+)
+
+// viewMarkerFileName is the name of the file identifying a directory as a view, so an existing view
+// can be replaced without risking to remove anything else
+const viewMarkerFileName = ".stack-view"
+
+// viewDirs is the list of the directories of the components merged in a view
+var viewDirs = []string{"bin", "lib", "lib64", "include", "share"}
+
+// ViewConflict is a file provided by several components, which therefore cannot be merged in a view
+type ViewConflict struct {
+	// Path is the path of the file relative to the view
+	Path string
+
+	// Components is the identifier of the components providing the file
+	Components []string
+}
+
+// ViewConflictError is the error returned when the components of a stack cannot be merged in a view
+type ViewConflictError struct {
+	Conflicts []ViewConflict
+}
+
+func (e *ViewConflictError) Error() string {
+	var msgs []string
+	for _, conflict := range e.Conflicts {
+		msgs = append(msgs, fmt.Sprintf("%s is provided by %s", conflict.Path, strings.Join(conflict.Components, ", ")))
+	}
+	return "conflicting files: " + strings.Join(msgs, "; ")
+}
+
+// getViewFiles returns the path of all the files a component provides to a view, relative to its
+// install directory. Directories are not part of the list, only the files and symbolic links they
+// include.
+func getViewFiles(installDir string) ([]string, error) {
+	var files []string
+	for _, dir := range viewDirs {
+		root := filepath.Join(installDir, dir)
+		if !util.IsDir(root) {
+			continue
+		}
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			relPath, err := filepath.Rel(installDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, relPath)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the files of %s: %w", root, err)
+		}
+	}
+	return files, nil
+}
+
+// CreateView creates a single prefix in dir, with bin, lib, include and share directories, where
+// the files of all the installed components of the stack are symbolic links to their install
+// directories. Using the whole stack then only requires to add the view to PATH, LD_LIBRARY_PATH,
+// etc. An existing view in dir is replaced. Nothing is created when several components provide the
+// same file, the conflicts are returned as a *ViewConflictError.
+func (c *Config) CreateView(dir string) error {
+	if !c.loaded {
+		err := c.Load()
+		if err != nil {
+			return fmt.Errorf("unable to load configuration: %w", err)
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("unable to get the absolute path of %s: %w", dir, err)
+	}
+	if util.PathExists(dir) && !util.FileExists(filepath.Join(dir, viewMarkerFileName)) {
+		return fmt.Errorf("%s exists and is not a view", dir)
+	}
+
+	// Every file of the view is mapped to the component providing it, conflicts being all the files
+	// provided by more than one component
+	providers := make(map[string][]string)
+	targets := make(map[string]string)
+	for idx := range c.StackDefinition.Components {
+		comp := &c.StackDefinition.Components[idx]
+		installDir := c.getComponentInstallDir(comp)
+		if !util.IsDir(installDir) {
+			log.Printf("%s is not installed, it is not part of the view", comp.id())
+			continue
+		}
+		files, err := getViewFiles(installDir)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			providers[file] = append(providers[file], comp.id())
+			targets[file] = filepath.Join(installDir, file)
+		}
+	}
+	conflictErr := new(ViewConflictError)
+	var files []string
+	for file, comps := range providers {
+		files = append(files, file)
+		if len(comps) > 1 {
+			conflictErr.Conflicts = append(conflictErr.Conflicts, ViewConflict{Path: file, Components: comps})
+		}
+	}
+	if len(conflictErr.Conflicts) > 0 {
+		sort.Slice(conflictErr.Conflicts, func(i, j int) bool {
+			return conflictErr.Conflicts[i].Path < conflictErr.Conflicts[j].Path
+		})
+		return conflictErr
+	}
+	sort.Strings(files)
+
+	// The view is created next to its final location and then moved in place so users never see a
+	// partial view
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), "."+filepath.Base(dir))
+	if err != nil {
+		return fmt.Errorf("unable to create a temporary directory for the view: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	err = os.Chmod(tmpDir, defaultPermission)
+	if err != nil {
+		return fmt.Errorf("unable to set the permissions of %s: %w", tmpDir, err)
+	}
+	for _, file := range files {
+		path := filepath.Join(tmpDir, file)
+		err := os.MkdirAll(filepath.Dir(path), defaultPermission)
+		if err != nil {
+			return fmt.Errorf("unable to create %s: %w", filepath.Dir(path), err)
+		}
+		err = os.Symlink(targets[file], path)
+		if err != nil {
+			return fmt.Errorf("unable to create link to %s: %w", targets[file], err)
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, viewMarkerFileName), []byte(c.StackDefinition.Name+"\n"), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to create the view marker: %w", err)
+	}
+
+	oldDir := ""
+	if util.PathExists(dir) {
+		oldDir = tmpDir + ".old"
+		err = os.Rename(dir, oldDir)
+		if err != nil {
+			return fmt.Errorf("unable to move the previous view %s: %w", dir, err)
+		}
+	}
+	err = os.Rename(tmpDir, dir)
+	if err != nil {
+		if oldDir != "" {
+			_ = os.Rename(oldDir, dir)
+		}
+		return fmt.Errorf("unable to move %s to %s: %w", tmpDir, dir, err)
+	}
+	if oldDir != "" {
+		err = os.RemoveAll(oldDir)
+		if err != nil {
+			log.Printf("unable to remove the previous view %s: %s", oldDir, err)
+		}
+	}
+
+	log.Printf("View successfully created, to use it: export PATH=%s:$PATH LD_LIBRARY_PATH=%s:$LD_LIBRARY_PATH", filepath.Join(dir, "bin"), filepath.Join(dir, "lib"))
+	return nil
+}
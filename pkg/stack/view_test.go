@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2026, NVIDIA CORPORATION. All rights reserved.
+//
+// See LICENSE.txt for license information
+//
+
+package stack
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCreateView(t *testing.T) {
+	def := &StackDef{
+		Name: "test",
+		Components: []Component{
+			{Name: "hwloc"},
+			{Name: "ompi", ConfigureDependency: "hwloc"},
+			{Name: "ucx"},
+		},
+	}
+	c, cleanupFn := newTestConfig(t, def)
+	defer cleanupFn()
+
+	// ucx is not installed and therefore not part of the view
+	files := map[string][]string{
+		"hwloc": {"bin/lstopo", "lib/libhwloc.so", "include/hwloc.h"},
+		"ompi":  {"bin/mpirun", "lib/libmpi.so", "share/openmpi/help.txt"},
+	}
+	for name, paths := range files {
+		for _, path := range paths {
+			fullPath := filepath.Join(c.getComponentInstallDir(c.getComponent(name)), path)
+			err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+			if err != nil {
+				t.Fatalf("unable to create %s: %s", filepath.Dir(fullPath), err)
+			}
+			err = ioutil.WriteFile(fullPath, []byte(name), 0644)
+			if err != nil {
+				t.Fatalf("unable to create %s: %s", fullPath, err)
+			}
+		}
+	}
+
+	viewDir := filepath.Join(c.StackConfig.InstallDir, "view")
+	err := c.CreateView(viewDir)
+	if err != nil {
+		t.Fatalf("unable to create the view: %s", err)
+	}
+	for name, paths := range files {
+		for _, path := range paths {
+			content, err := ioutil.ReadFile(filepath.Join(viewDir, path))
+			if err != nil || string(content) != name {
+				t.Fatalf("%s is not provided by %s in the view (%v)", path, name, err)
+			}
+		}
+	}
+
+	// A file provided by more than one component is a conflict and the existing view is left untouched
+	conflictPath := filepath.Join(c.getComponentInstallDir(c.getComponent("ompi")), "bin", "lstopo")
+	err = ioutil.WriteFile(conflictPath, []byte("ompi"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create %s: %s", conflictPath, err)
+	}
+	err = c.CreateView(viewDir)
+	var conflictErr *ViewConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Path != "bin/lstopo" || !reflect.DeepEqual(conflictErr.Conflicts[0].Components, []string{"hwloc", "ompi"}) {
+		t.Fatalf("the conflict was not detected: %v", err)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(viewDir, "bin", "lstopo")); err != nil || string(content) != "hwloc" {
+		t.Fatalf("the existing view was modified (%v)", err)
+	}
+
+	// A directory that is not a view is never replaced
+	err = c.CreateView(c.getStackBasedir())
+	if err == nil {
+		t.Fatalf("a directory that is not a view was replaced")
+	}
+}